@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+var accessLogger *log.Logger
+
+// statusRecorder records the status of a response, passing flushes and
+// hijacks through for streams and WebSockets
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends buffered data to the client
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection, for WebSockets
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	sr.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// open the access log, which is the main log unless a file is given
+func openAccessLog() error {
+	if !*accessLog {
+		return nil
+	}
+	if *accessLogFile == "" {
+		accessLogger = logger
+		return nil
+	}
+	f, err := os.OpenFile(*accessLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	accessLogger = log.New(f, "", log.LstdFlags)
+	return nil
+}
+
+// log every request with who made it, so there's a record of who controls
+// the scanner
+func logAccess(handler http.Handler) http.Handler {
+	if accessLogger == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(sr, r)
+		user := "-"
+		if session := requestSession(r); session != nil {
+			user = session.User
+		} else if u, _, ok := r.BasicAuth(); ok {
+			user = u
+		}
+		accessLogger.Printf("access method=%s path=%q status=%d duration=%s remote=%s user=%q",
+			r.Method, redactedURI(r.URL), sr.status, time.Since(start), r.RemoteAddr, user)
+	})
+}
+
+// the path and query of the URL, without the value of the token parameter
+// since it's a credential
+func redactedURI(u *url.URL) string {
+	query := u.Query()
+	if _, ok := query["token"]; !ok {
+		return u.RequestURI()
+	}
+	query.Set("token", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.RequestURI()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// set the server up to obtain and renew its certificates from Let's Encrypt,
+// answering HTTP-01 challenges on a separate listener
+func useACME(server *http.Server) {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(strings.Split(*acmeHost, ",")...),
+		Cache:      autocert.DirCache(*acmeCache),
+		Email:      *acmeEmail,
+	}
+	server.TLSConfig = m.TLSConfig()
+	go func() {
+		logger.Println("Answering ACME challenges at", *acmeHTTP)
+		err := http.ListenAndServe(*acmeHTTP, m.HTTPHandler(nil))
+		if err != nil {
+			logger.Println("ACME challenge listener failed:", err)
+		}
+	}()
+}
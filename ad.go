@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/sausheong/ble"
+)
+
+// AD types [Core Specification Supplement, Part A, 1]
+const (
+	adFlags               = 0x01
+	adIncomplete16        = 0x02
+	adComplete16          = 0x03
+	adIncomplete32        = 0x04
+	adComplete32          = 0x05
+	adIncomplete128       = 0x06
+	adComplete128         = 0x07
+	adShortName           = 0x08
+	adCompleteName        = 0x09
+	adTxPower             = 0x0a
+	adServiceData16       = 0x16
+	adServiceData32       = 0x20
+	adServiceData128      = 0x21
+	adManufacturerData    = 0xff
+	adSolicitation16      = 0x14
+	adSolicitation128     = 0x15
+	adSolicitation32      = 0x1f
+	adAppearance          = 0x19
+	adAdvertisingInterval = 0x1a
+	adURI                 = 0x24
+)
+
+// names of the AD types
+var adTypeNames = map[uint8]string{
+	adFlags:               "Flags",
+	adIncomplete16:        "Incomplete List of 16-bit Service UUIDs",
+	adComplete16:          "Complete List of 16-bit Service UUIDs",
+	adIncomplete32:        "Incomplete List of 32-bit Service UUIDs",
+	adComplete32:          "Complete List of 32-bit Service UUIDs",
+	adIncomplete128:       "Incomplete List of 128-bit Service UUIDs",
+	adComplete128:         "Complete List of 128-bit Service UUIDs",
+	adShortName:           "Shortened Local Name",
+	adCompleteName:        "Complete Local Name",
+	adTxPower:             "Tx Power Level",
+	adServiceData16:       "Service Data - 16-bit UUID",
+	adServiceData32:       "Service Data - 32-bit UUID",
+	adServiceData128:      "Service Data - 128-bit UUID",
+	adManufacturerData:    "Manufacturer Specific Data",
+	adSolicitation16:      "List of 16-bit Service Solicitation UUIDs",
+	adSolicitation128:     "List of 128-bit Service Solicitation UUIDs",
+	adSolicitation32:      "List of 32-bit Service Solicitation UUIDs",
+	adAppearance:          "Appearance",
+	adAdvertisingInterval: "Advertising Interval",
+	adURI:                 "URI",
+}
+
+// names of the bits in the flags AD structure
+var adFlagNames = []string{
+	"LE Limited Discoverable Mode",
+	"LE General Discoverable Mode",
+	"BR/EDR Not Supported",
+	"Simultaneous LE and BR/EDR (Controller)",
+	"Simultaneous LE and BR/EDR (Host)",
+}
+
+// sources of AD structures
+const (
+	SourceAdv     = "adv"
+	SourceScanRsp = "scanrsp"
+)
+
+// ADStructure is a single AD structure in an advertisement
+type ADStructure struct {
+	Type     uint8  `json:"type"`
+	TypeName string `json:"typename"`
+	Data     string `json:"data"`
+	// whether it's from the advertisement or the scan response
+	Source string `json:"source"`
+}
+
+// ADServiceData is the service data for a service UUID
+type ADServiceData struct {
+	UUID string `json:"uuid"`
+	Data string `json:"data"`
+}
+
+// ADManufacturerData is manufacturer specific data
+type ADManufacturerData struct {
+	CompanyID uint16 `json:"companyid"`
+	Data      string `json:"data"`
+}
+
+// AdvertisingData is the parsed content of an advertisement and its scan
+// response
+type AdvertisingData struct {
+	Flags            []string             `json:"flags,omitempty"`
+	CompleteName     string               `json:"completename,omitempty"`
+	ShortName        string               `json:"shortname,omitempty"`
+	TxPower          *int                 `json:"txpower,omitempty"`
+	Appearance       *uint16              `json:"appearance,omitempty"`
+	Services         []string             `json:"services,omitempty"`
+	ServiceData      []ADServiceData      `json:"servicedata,omitempty"`
+	ManufacturerData []ADManufacturerData `json:"manufacturerdata,omitempty"`
+	Structures       []ADStructure        `json:"structures"`
+}
+
+// rawAdvertisement is implemented by advertisements that give access to
+// the advertising data and scan response bytes, like the linux HCI ones
+type rawAdvertisement interface {
+	Data() []byte
+	ScanResponse() []byte
+}
+
+// parse the AD structures of the advertisement, returns nil if the
+// advertisement doesn't give access to its raw data
+func parseAdvertisement(a interface{}) *AdvertisingData {
+	raw, ok := a.(rawAdvertisement)
+	if !ok {
+		return nil
+	}
+	ad := &AdvertisingData{Structures: []ADStructure{}}
+	ad.parse(raw.Data(), SourceAdv)
+	ad.parse(raw.ScanResponse(), SourceScanRsp)
+	return ad
+}
+
+// the TX power level the advertisement gives, from its AD structures, or nil
+// if it doesn't give one. Advertisements without raw data only say 0 when
+// there's no TX power, so 0 is taken as no TX power for them
+func txPowerLevel(a ble.Advertisement, ad *AdvertisingData) *int {
+	if ad != nil {
+		return ad.TxPower
+	}
+	if power := a.TxPowerLevel(); power != 0 {
+		return &power
+	}
+	return nil
+}
+
+// parse the AD structures in the data and add them to the advertising data
+func (ad *AdvertisingData) parse(data []byte, source string) {
+	for len(data) > 1 {
+		length := int(data[0])
+		if length == 0 || length >= len(data) {
+			return
+		}
+		typ, value := data[1], data[2:length+1]
+		data = data[length+1:]
+
+		ad.Structures = append(ad.Structures, ADStructure{
+			Type:     typ,
+			TypeName: adTypeName(typ),
+			Data:     hex.EncodeToString(value),
+			Source:   source,
+		})
+		switch typ {
+		case adFlags:
+			if len(value) > 0 {
+				for i, name := range adFlagNames {
+					if value[0]&(1<<uint(i)) != 0 {
+						ad.Flags = append(ad.Flags, name)
+					}
+				}
+			}
+		case adCompleteName:
+			ad.CompleteName = clean(string(value))
+		case adShortName:
+			ad.ShortName = clean(string(value))
+		case adTxPower:
+			if len(value) > 0 {
+				txPower := int(int8(value[0]))
+				ad.TxPower = &txPower
+			}
+		case adAppearance:
+			if len(value) >= 2 {
+				appearance := binary.LittleEndian.Uint16(value)
+				ad.Appearance = &appearance
+			}
+		case adIncomplete16, adComplete16:
+			ad.Services = append(ad.Services, formatUUIDs(value, 2)...)
+		case adIncomplete32, adComplete32:
+			ad.Services = append(ad.Services, formatUUIDs(value, 4)...)
+		case adIncomplete128, adComplete128:
+			ad.Services = append(ad.Services, formatUUIDs(value, 16)...)
+		case adServiceData16:
+			ad.addServiceData(value, 2)
+		case adServiceData32:
+			ad.addServiceData(value, 4)
+		case adServiceData128:
+			ad.addServiceData(value, 16)
+		case adManufacturerData:
+			if len(value) >= 2 {
+				ad.ManufacturerData = append(ad.ManufacturerData, ADManufacturerData{
+					CompanyID: binary.LittleEndian.Uint16(value[0:2]),
+					Data:      hex.EncodeToString(value[2:]),
+				})
+			}
+		}
+	}
+}
+
+// add service data, where the UUID is the first size bytes of the value
+func (ad *AdvertisingData) addServiceData(value []byte, size int) {
+	if len(value) < size {
+		return
+	}
+	ad.ServiceData = append(ad.ServiceData, ADServiceData{
+		UUID: formatLEUUID(value[:size]),
+		Data: hex.EncodeToString(value[size:]),
+	})
+}
+
+// name of the AD type
+func adTypeName(typ uint8) string {
+	if name, ok := adTypeNames[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown (0x%02x)", typ)
+}
+
+// format a list of little-endian UUIDs of the given size
+func formatUUIDs(b []byte, size int) (uuids []string) {
+	for i := 0; i+size <= len(b); i += size {
+		uuids = append(uuids, formatLEUUID(b[i:i+size]))
+	}
+	return
+}
+
+// format a little-endian 16, 32 or 128-bit UUID
+func formatLEUUID(b []byte) string {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	if len(reversed) == 16 {
+		return formatUUID(reversed)
+	}
+	return hex.EncodeToString(reversed)
+}
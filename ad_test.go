@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseAdvertisingData(t *testing.T) {
+	data, _ := hex.DecodeString("020106" + // flags
+		"050941424307" + // complete name, with a control character to clean
+		"020af4" + // TX power -12 dBm
+		"021980" + // appearance, too short so it's ignored
+		"00" + // a zero length, which ends the data
+		"020af0")
+	ad := &AdvertisingData{Structures: []ADStructure{}}
+	ad.parse(data, SourceAdv)
+	if len(ad.Flags) != 2 || ad.Flags[0] != "LE General Discoverable Mode" || ad.Flags[1] != "BR/EDR Not Supported" {
+		t.Errorf("got flags %v", ad.Flags)
+	}
+	if ad.CompleteName != "ABC" {
+		t.Errorf("got name %q, want ABC", ad.CompleteName)
+	}
+	if ad.TxPower == nil || *ad.TxPower != -12 {
+		t.Errorf("got TX power %v, want -12", ad.TxPower)
+	}
+	if ad.Appearance != nil {
+		t.Errorf("got appearance %d from a truncated structure", *ad.Appearance)
+	}
+	if len(ad.Structures) != 4 || ad.Structures[2].TypeName != "Tx Power Level" || ad.Structures[2].Data != "f4" {
+		t.Errorf("got structures %+v", ad.Structures)
+	}
+}
+
+func TestParseAdvertisingDataServices(t *testing.T) {
+	data, _ := hex.DecodeString("05030f180a18" + // 16-bit services 180f and 180a
+		"0716d2fc4002ca09" + // BTHome service data
+		"11079ecadc240ee5a9e093f3a3b50100406e" + // Nordic UART service
+		"07ff4c0012020003" + // Apple manufacturer data
+		"02ff4c" + // manufacturer data too short for a company ID
+		"0216d2") // service data too short for its UUID
+	ad := &AdvertisingData{Structures: []ADStructure{}}
+	ad.parse(data, SourceScanRsp)
+	want := []string{"180f", "180a", "6e400001-b5a3-f393-e0a9-e50e24dcca9e"}
+	if len(ad.Services) != len(want) {
+		t.Fatalf("got services %v, want %v", ad.Services, want)
+	}
+	for i := range want {
+		if ad.Services[i] != want[i] {
+			t.Errorf("got services %v, want %v", ad.Services, want)
+		}
+	}
+	if len(ad.ServiceData) != 1 || ad.ServiceData[0] != (ADServiceData{UUID: "fcd2", Data: "4002ca09"}) {
+		t.Errorf("got service data %+v", ad.ServiceData)
+	}
+	if len(ad.ManufacturerData) != 1 || ad.ManufacturerData[0] != (ADManufacturerData{CompanyID: 0x004c, Data: "12020003"}) {
+		t.Errorf("got manufacturer data %+v", ad.ManufacturerData)
+	}
+	for _, s := range ad.Structures {
+		if s.Source != SourceScanRsp {
+			t.Errorf("got source %q, want %q", s.Source, SourceScanRsp)
+		}
+	}
+}
+
+func TestParseAdvertisingDataTruncated(t *testing.T) {
+	for _, data := range []string{"", "02", "0201", "05094142", "ff09414243"} {
+		b, _ := hex.DecodeString(data)
+		ad := &AdvertisingData{Structures: []ADStructure{}}
+		ad.parse(b, SourceAdv)
+		if len(ad.Structures) != 0 {
+			t.Errorf("parsed %+v from truncated data %s", ad.Structures, data)
+		}
+	}
+}
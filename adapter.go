@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// adapter states
+const (
+	AdapterOK         = "ok"
+	AdapterRecovering = "recovering"
+	AdapterUnplugged  = "unplugged"
+	AdapterFinished   = "finished"
+)
+
+// Adapter is an HCI adapter, or another scanner, used for scanning
+type Adapter struct {
+	ID      int
+	Name    string
+	Address string
+	Scanner Scanner
+
+	// opens the scanner again, to reset it
+	open func() (Scanner, error)
+
+	mutex             sync.Mutex
+	state             string
+	errors            int
+	consecutiveErrors int
+	recoveries        int
+	lastError         string
+	lastAdvertisement time.Time
+	// when the adapter started scanning or was last reopened
+	since time.Time
+}
+
+// AdapterStatus is the state and error counters of an adapter
+type AdapterStatus struct {
+	ID                int       `json:"id"`
+	Name              string    `json:"name"`
+	Address           string    `json:"address"`
+	State             string    `json:"state"`
+	Errors            int       `json:"errors"`
+	ConsecutiveErrors int       `json:"consecutiveerrors"`
+	Recoveries        int       `json:"recoveries"`
+	LastError         string    `json:"lasterror,omitempty"`
+	LastAdvertisement time.Time `json:"lastadvertisement"`
+}
+
+// Sighting is when and how strongly an adapter last heard a device
+type Sighting struct {
+	RSSI     int       `json:"rssi"`
+	Detected time.Time `json:"detected"`
+}
+
+// adapters used for scanning, the first one is also used for connecting
+// and advertising
+var adapters []*Adapter
+var adaptersMutex sync.Mutex
+
+// open the HCI adapters with the given names (hci0) or IDs (0), or the
+// simulator or replay scanners
+func openAdapters(names []string) error {
+	opened := []*Adapter{}
+	for _, name := range names {
+		id, open, err := scannerOpener(name)
+		if err != nil {
+			closeAdapters(opened)
+			return err
+		}
+		scanner, err := open()
+		if err != nil {
+			closeAdapters(opened)
+			return err
+		}
+		if id >= 0 {
+			name = "hci" + strconv.Itoa(id)
+		}
+		opened = append(opened, &Adapter{
+			ID:      id,
+			Name:    strings.TrimSpace(name),
+			Address: scanner.Address(),
+			Scanner: scanner,
+			open:    open,
+			state:   AdapterOK,
+		})
+	}
+	if len(opened) == 0 {
+		return errors.New("no adapters given")
+	}
+	adapters = opened
+	useFirstAdapter()
+	return nil
+}
+
+// connect and advertise with the first adapter, if it's an HCI adapter
+func useFirstAdapter() {
+	bleDevice = nil
+	if h, ok := adapters[0].Scanner.(*hciScanner); ok {
+		ble.SetDefaultDevice(h.Device)
+		bleDevice = h.Device
+	}
+}
+
+// parse an adapter name (hci0) or ID (0) into the adapter ID
+func parseAdapter(name string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(name), "hci"))
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid adapter %q", name)
+	}
+	return id, nil
+}
+
+// close the adapters
+func closeAdapters(list []*Adapter) {
+	for _, adapter := range list {
+		err := adapter.Scanner.Close()
+		if err != nil {
+			logger.Println("Cannot close", adapter.Name, err)
+		}
+	}
+}
+
+// switch scanning, connecting and advertising over to the adapters with
+// the given names, going back to the current ones if they can't be opened
+func switchAdapters(names []string) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	adaptersMutex.Lock()
+	defer adaptersMutex.Unlock()
+	wasScanning := !stop.Load()
+	stop.Store(true)
+	scanning.Wait()
+	endAdvertising()
+	current := []string{}
+	for _, adapter := range adapters {
+		current = append(current, adapter.Name)
+	}
+	closeAdapters(adapters)
+
+	err := openAdapters(names)
+	if err != nil {
+		if e := openAdapters(current); e != nil {
+			logger.Println("Cannot reopen adapters:", e)
+		}
+	} else {
+		logger.Println("Switched to adapters", names)
+	}
+	if peripheral {
+		if e := startPeripheral(); e != nil {
+			logger.Println("Cannot add GATT service:", e)
+		}
+	}
+	if wasScanning {
+		runScan()
+	}
+	return err
+}
+
+// names of the HCI adapters present in the system
+func availableAdapters() []string {
+	names := []string{}
+	entries, err := os.ReadDir("/sys/class/bluetooth")
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		if _, err := parseAdapter(entry.Name()); err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// scan on the adapter until scanning is stopped, reopening the adapter if
+// it stops working
+func (adapter *Adapter) scan(wg *sync.WaitGroup) {
+	defer wg.Done()
+	handler := func(a ble.Advertisement) {
+		adapter.seen()
+		adScanHandler(adapter, a)
+	}
+	adapter.mutex.Lock()
+	adapter.since = time.Now()
+	adapter.mutex.Unlock()
+	for !stop.Load() {
+		if adapter.unplugged() {
+			adapter.replug()
+			continue
+		}
+		if quiet() {
+			adapter.hush()
+			continue
+		}
+		scanRestarts.Inc()
+		statScanCycles.Add(1)
+		settings := currentScanSettings()
+		err := adapter.Scanner.Configure(settings)
+		if err != nil {
+			adapter.failed(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), settings.scanDuration())
+		err = adapter.Scanner.Scan(ctx, settings.Duplicates, handler)
+		cancel()
+		if err == io.EOF {
+			adapter.finish()
+			return
+		}
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			adapter.failed(err)
+		}
+		if adapter.wedged() {
+			adapter.recover()
+			continue
+		}
+		adapter.sleep(settings.sleepDuration())
+	}
+}
+
+// stop scanning on the adapter for good, when its scanner has nothing more
+// to give, like a replayed capture that has ended
+func (adapter *Adapter) finish() {
+	logger.Println("Adapter", adapter.Name, "has finished")
+	adapter.mutex.Lock()
+	adapter.state = AdapterFinished
+	adapter.mutex.Unlock()
+}
+
+// keep the radio off until quiet hours are over or scanning is stopped
+func (adapter *Adapter) hush() {
+	logger.Println("Adapter", adapter.Name, "is quiet")
+	for quiet() && !stop.Load() {
+		time.Sleep(time.Second)
+	}
+	adapter.mutex.Lock()
+	adapter.since = time.Now()
+	adapter.mutex.Unlock()
+	logger.Println("Adapter", adapter.Name, "is no longer quiet")
+}
+
+// keep the radio off for the duty cycle, waking early if scanning is stopped
+func (adapter *Adapter) sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	for end := time.Now().Add(d); !stop.Load() && time.Now().Before(end); {
+		time.Sleep(100 * time.Millisecond)
+	}
+	// the adapter isn't silent while sleeping
+	adapter.mutex.Lock()
+	adapter.since = time.Now()
+	adapter.mutex.Unlock()
+}
+
+// record that the adapter received an advertisement
+func (adapter *Adapter) seen() {
+	adapter.mutex.Lock()
+	adapter.lastAdvertisement = time.Now()
+	adapter.mutex.Unlock()
+}
+
+// record a scan error
+func (adapter *Adapter) failed(err error) {
+	logger.Println("Scan on", adapter.Name, "failed:", err)
+	adapterErrorsTotal.WithLabelValues(adapter.Name).Inc()
+	adapter.mutex.Lock()
+	adapter.errors++
+	adapter.consecutiveErrors++
+	adapter.lastError = err.Error()
+	adapter.mutex.Unlock()
+}
+
+// check if scans keep failing or, if asked to, an HCI adapter hasn't
+// received any advertisements for too long. An adapter whose device is
+// closed under it is handled as unplugged. Other scanners, like a replayed
+// capture with a gap in it, can be silent without anything being wrong, as
+// can an adapter with a whitelist
+func (adapter *Adapter) wedged() bool {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+	if adapter.consecutiveErrors >= *adapterErrors {
+		return true
+	}
+	if _, ok := adapter.Scanner.(*hciScanner); !ok || len(currentScanSettings().Whitelist) > 0 {
+		return false
+	}
+	last := adapter.lastAdvertisement
+	if adapter.since.After(last) {
+		last = adapter.since
+	}
+	return *adapterSilence > 0 && time.Since(last) > *adapterSilence
+}
+
+// close and reopen the adapter, which resets it, retrying with backoff
+// until it works or scanning is stopped
+func (adapter *Adapter) recover() {
+	logger.Println("Adapter", adapter.Name, "is not working, reopening it")
+	adapter.mutex.Lock()
+	adapter.state = AdapterRecovering
+	adapter.mutex.Unlock()
+	adapter.Scanner.Close()
+
+	backoff := time.Second
+	for !stop.Load() {
+		scanner, err := adapter.open()
+		if err == nil {
+			adapter.reopened(scanner)
+			return
+		}
+		adapter.failed(err)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// check if the adapter has been removed from the system
+func (adapter *Adapter) unplugged() bool {
+	return adapter.Scanner.Unplugged()
+}
+
+// wait for the unplugged adapter to come back and reopen it, until
+// scanning is stopped
+func (adapter *Adapter) replug() {
+	logger.Println("Adapter", adapter.Name, "was removed, waiting for it to return")
+	adapter.mutex.Lock()
+	adapter.state = AdapterUnplugged
+	adapter.mutex.Unlock()
+	adapter.Scanner.Close()
+
+	for !stop.Load() {
+		time.Sleep(time.Second)
+		scanner, err := adapter.open()
+		if errors.Is(err, errNotPresent) {
+			continue
+		}
+		if err != nil {
+			adapter.failed(err)
+			continue
+		}
+		adapter.reopened(scanner)
+		return
+	}
+}
+
+// use the reopened scanner for the adapter
+func (adapter *Adapter) reopened(scanner Scanner) {
+	adapter.mutex.Lock()
+	adapter.Scanner = scanner
+	adapter.state = AdapterOK
+	adapter.consecutiveErrors = 0
+	adapter.recoveries++
+	adapter.since = time.Now()
+	adapter.mutex.Unlock()
+	adapterRecoveriesTotal.WithLabelValues(adapter.Name).Inc()
+	logger.Println("Reopened adapter", adapter.Name)
+
+	if adapter == adapters[0] {
+		useFirstAdapter()
+		if peripheral {
+			if err := startPeripheral(); err != nil {
+				logger.Println("Cannot add GATT service:", err)
+			}
+		}
+	}
+}
+
+// the state and error counters of the adapter
+func (adapter *Adapter) Status() AdapterStatus {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+	return AdapterStatus{
+		ID:                adapter.ID,
+		Name:              adapter.Name,
+		Address:           adapter.Address,
+		State:             adapter.state,
+		Errors:            adapter.errors,
+		ConsecutiveErrors: adapter.consecutiveErrors,
+		Recoveries:        adapter.recoveries,
+		LastError:         adapter.lastError,
+		LastAdvertisement: adapter.lastAdvertisement,
+	}
+}
+
+// the status of all adapters in use
+func adapterStatuses() []AdapterStatus {
+	statuses := []AdapterStatus{}
+	for _, adapter := range adapters {
+		statuses = append(statuses, adapter.Status())
+	}
+	return statuses
+}
+
+// merge the adapter's sighting of the device with the previous ones,
+// dropping those that have expired
+func mergeSightings(previous map[string]Sighting, adapter *Adapter, sighting Sighting) map[string]Sighting {
+	sightings := map[string]Sighting{adapter.Name: sighting}
+	for name, s := range previous {
+		if name != adapter.Name && time.Since(s.Detected) < expiry() {
+			sightings[name] = s
+		}
+	}
+	return sightings
+}
+
+// the strongest RSSI among the adapters that heard the device within the
+// last scan cycle
+func strongestRSSI(sightings map[string]Sighting) (rssi int) {
+	settings := currentScanSettings()
+	cycle := settings.scanDuration() + settings.sleepDuration()
+	first := true
+	for _, s := range sightings {
+		if time.Since(s.Detected) > cycle {
+			continue
+		}
+		if first || s.RSSI > rssi {
+			rssi = s.RSSI
+			first = false
+		}
+	}
+	return
+}
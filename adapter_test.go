@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// fakeScanner returns the errors given, one for each scan, and then io.EOF
+type fakeScanner struct {
+	errs      []error
+	unplugged bool
+	closed    bool
+}
+
+func (f *fakeScanner) Configure(s ScanSettings) error { return nil }
+
+func (f *fakeScanner) Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error {
+	if len(f.errs) == 0 {
+		return io.EOF
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *fakeScanner) Unplugged() bool { return f.unplugged }
+func (f *fakeScanner) Address() string { return "" }
+func (f *fakeScanner) Close() error    { f.closed = true; return nil }
+
+func init() {
+	logger = log.New(io.Discard, "", 0)
+}
+
+// an adapter scanning with the scanner, reopened with the ones given in turn
+func fakeAdapter(scanner Scanner, reopen ...Scanner) *Adapter {
+	adapter := &Adapter{Name: "fake", Scanner: scanner, state: AdapterOK}
+	adapter.open = func() (Scanner, error) {
+		if len(reopen) == 0 {
+			return nil, errors.New("no more scanners")
+		}
+		s := reopen[0]
+		reopen = reopen[1:]
+		return s, nil
+	}
+	adapters = []*Adapter{adapter}
+	return adapter
+}
+
+// scan with the adapter until it's finished
+func scanUntilFinished(t *testing.T, adapter *Adapter) {
+	stop.Store(false)
+	defer stop.Store(true)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	done := make(chan bool)
+	go func() {
+		adapter.scan(wg)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("adapter didn't finish")
+	}
+}
+
+func TestAdapterFinishes(t *testing.T) {
+	adapter := fakeAdapter(&fakeScanner{})
+	scanUntilFinished(t, adapter)
+	status := adapter.Status()
+	if status.State != AdapterFinished || status.Errors != 0 || status.Recoveries != 0 {
+		t.Errorf("got %+v, want finished without errors or recoveries", status)
+	}
+}
+
+func TestAdapterRecoversAfterErrors(t *testing.T) {
+	failed := errors.New("command disallowed")
+	first := &fakeScanner{errs: []error{failed, failed, failed}}
+	second := &fakeScanner{}
+	adapter := fakeAdapter(first, second)
+	scanUntilFinished(t, adapter)
+	status := adapter.Status()
+	if status.State != AdapterFinished || status.Errors != *adapterErrors || status.Recoveries != 1 ||
+		status.ConsecutiveErrors != 0 || status.LastError != failed.Error() {
+		t.Errorf("got %+v, want finished after %d errors and a recovery", status, *adapterErrors)
+	}
+	if !first.closed || adapter.Scanner != second {
+		t.Error("adapter wasn't reopened")
+	}
+}
+
+func TestAdapterNotWedgedBeforeErrors(t *testing.T) {
+	adapter := fakeAdapter(&fakeScanner{})
+	for i := 1; i < *adapterErrors; i++ {
+		adapter.failed(errors.New("timeout"))
+	}
+	if adapter.wedged() {
+		t.Errorf("wedged after %d errors, want %d", *adapterErrors-1, *adapterErrors)
+	}
+}
+
+func TestAdapterSilenceOnlyForHCI(t *testing.T) {
+	silence := *adapterSilence
+	defer func() { *adapterSilence = silence }()
+	*adapterSilence = time.Millisecond
+	adapter := fakeAdapter(&fakeScanner{})
+	adapter.since = time.Now().Add(-time.Hour)
+	if adapter.wedged() {
+		t.Error("silent scanner that isn't an HCI adapter is wedged")
+	}
+}
+
+func TestAdapterReplugged(t *testing.T) {
+	first := &fakeScanner{unplugged: true}
+	second := &fakeScanner{}
+	adapter := fakeAdapter(first, second)
+	scanUntilFinished(t, adapter)
+	status := adapter.Status()
+	if status.State != AdapterFinished || status.Recoveries != 1 || !first.closed || adapter.Scanner != second {
+		t.Errorf("got %+v, want finished after being replugged", status)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"strings"
+)
+
+// kinds of Bluetooth device addresses
+const (
+	AddressPublic        = "public"
+	AddressStatic        = "static"
+	AddressResolvable    = "resolvable"
+	AddressNonResolvable = "nonresolvable"
+)
+
+// classify the advertiser's address as public, random static, resolvable
+// private or non-resolvable private, from the address type in the
+// advertising report and the top two bits of a random address. Returns ""
+// if the advertisement doesn't report the address type
+func classifyAddress(a interface{}, address string) string {
+	typed, ok := a.(addressTyped)
+	if !ok {
+		return ""
+	}
+	if typed.AddressType() == addressPublic {
+		return AddressPublic
+	}
+	mac, err := net.ParseMAC(address)
+	if err != nil || len(mac) == 0 {
+		return ""
+	}
+	switch mac[0] >> 6 {
+	case 0x03:
+		return AddressStatic
+	case 0x01:
+		return AddressResolvable
+	case 0x00:
+		return AddressNonResolvable
+	}
+	return ""
+}
+
+// only the devices with one of the comma-separated address types, or all of
+// them if no types are given
+func withAddressTypes(list []Device, types string) []Device {
+	if types == "" {
+		return list
+	}
+	wanted := map[string]bool{}
+	for _, typ := range strings.Split(types, ",") {
+		wanted[strings.TrimSpace(typ)] = true
+	}
+	filtered := []Device{}
+	for _, device := range list {
+		if wanted[device.AddressType] {
+			filtered = append(filtered, device)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sausheong/ble"
+	"github.com/sausheong/ble/linux/adv"
+	"github.com/sausheong/ble/linux/hci/cmd"
+)
+
+// advertising types
+const (
+	AdvertiseIBeacon      = "ibeacon"
+	AdvertiseEddystoneURL = "eddystone-url"
+	AdvertiseGATT         = "gatt"
+)
+
+// Advertising describes what blueblue is advertising
+type Advertising struct {
+	Type    string   `json:"type"`
+	IBeacon *IBeacon `json:"ibeacon,omitempty"`
+	// Eddystone-URL, with the calibrated TX power at 0m and the
+	// advertising interval in milliseconds
+	URL      string    `json:"url,omitempty"`
+	TxPower  int       `json:"txpower,omitempty"`
+	Interval int       `json:"interval,omitempty"`
+	Started  time.Time `json:"started"`
+}
+
+// the current advertising, if any, how to stop it and when it's stopped
+var advertising *Advertising
+var stopAdvertising context.CancelFunc
+var advertisingDone chan struct{}
+var advertisingMutex sync.Mutex
+
+// start advertising, replacing the current advertising if there is one
+func startAdvertising(a Advertising) error {
+	if bleDevice == nil {
+		return errNoHCI
+	}
+	run, err := advertiser(a)
+	if err != nil {
+		return err
+	}
+	advertisingMutex.Lock()
+	defer advertisingMutex.Unlock()
+	haltAdvertising()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	a.Started = time.Now()
+	current := &a
+	advertising, stopAdvertising, advertisingDone = current, cancel, done
+	logger.Println("Started advertising", a.Type)
+
+	go func() {
+		err := run(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.Println("Cannot advertise:", err)
+		}
+		close(done)
+		advertisingMutex.Lock()
+		if advertising == current {
+			advertising, stopAdvertising, advertisingDone = nil, nil, nil
+		}
+		advertisingMutex.Unlock()
+		logger.Println("Stopped advertising", a.Type)
+	}()
+	return nil
+}
+
+// stop the current advertising, returns false if there is none
+func endAdvertising() bool {
+	advertisingMutex.Lock()
+	defer advertisingMutex.Unlock()
+	if stopAdvertising == nil {
+		return false
+	}
+	haltAdvertising()
+	return true
+}
+
+// stop the current advertising and wait for the adapter to stop
+// advertising it, must be called with the advertising mutex held
+func haltAdvertising() {
+	if stopAdvertising == nil {
+		return
+	}
+	stopAdvertising()
+	<-advertisingDone
+	advertising, stopAdvertising, advertisingDone = nil, nil, nil
+}
+
+// the current advertising, nil if there is none
+func currentAdvertising() *Advertising {
+	advertisingMutex.Lock()
+	defer advertisingMutex.Unlock()
+	return advertising
+}
+
+// validate the advertising and return the function that advertises it
+// until the context is done
+func advertiser(a Advertising) (func(ctx context.Context) error, error) {
+	switch a.Type {
+	case AdvertiseIBeacon:
+		if a.IBeacon == nil {
+			return nil, errors.New("missing ibeacon parameters")
+		}
+		u, err := ble.Parse(a.IBeacon.UUID)
+		if err != nil || u.Len() != 16 {
+			return nil, fmt.Errorf("invalid iBeacon UUID %q", a.IBeacon.UUID)
+		}
+		if a.IBeacon.TxPower < -128 || a.IBeacon.TxPower > 127 {
+			return nil, fmt.Errorf("invalid TX power %d", a.IBeacon.TxPower)
+		}
+		b := *a.IBeacon
+		return func(ctx context.Context) error {
+			return ble.AdvertiseIBeacon(ctx, u, b.Major, b.Minor, int8(b.TxPower))
+		}, nil
+	case AdvertiseEddystoneURL:
+		frame, err := eddystoneURLFrame(a.URL, a.TxPower)
+		if err != nil {
+			return nil, err
+		}
+		interval := a.Interval
+		if interval == 0 {
+			interval = 1000
+		}
+		// Bluetooth 4.x controllers don't take non-connectable advertising
+		// more often than every 100ms
+		if interval < 100 || interval > 10240 {
+			return nil, fmt.Errorf("invalid interval %dms, must be between 100ms and 10240ms", interval)
+		}
+		return func(ctx context.Context) error {
+			return advertiseServiceData(ctx, 0xfeaa, frame, interval)
+		}, nil
+	case AdvertiseGATT:
+		if !peripheral {
+			return nil, errors.New("the GATT service is not enabled")
+		}
+		return func(ctx context.Context) error {
+			return ble.AdvertiseNameAndServices(ctx, "blueblue", scanServiceUUID)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown advertising type %q", a.Type)
+	}
+}
+
+// advertise the service data, with the service UUID in the list of
+// services, as a non-connectable advertisement at the given interval in
+// milliseconds until the context is done
+func advertiseServiceData(ctx context.Context, id uint16, data []byte, interval int) error {
+	h := bleDevice.HCI
+	units := uint16(float64(interval) / 0.625)
+	params := cmd.LESetAdvertisingParameters{
+		AdvertisingIntervalMin: units,
+		AdvertisingIntervalMax: units,
+		AdvertisingType:        0x03, // ADV_NONCONN_IND
+		AdvertisingChannelMap:  0x07, // all channels
+	}
+	err := h.Send(&params, nil)
+	if err != nil {
+		return err
+	}
+	// restore the default advertising parameters afterwards
+	defer h.Send(&cmd.LESetAdvertisingParameters{
+		AdvertisingIntervalMin: 0x0020,
+		AdvertisingIntervalMax: 0x0020,
+		AdvertisingChannelMap:  0x07,
+	}, nil)
+
+	ad, err := adv.NewPacket(
+		adv.Flags(adv.FlagGeneralDiscoverable|adv.FlagLEOnly),
+		adv.AllUUID(ble.UUID16(id)),
+		adv.ServiceData16(id, data),
+	)
+	if err != nil {
+		return err
+	}
+	err = h.SetAdvertisement(ad.Bytes(), nil)
+	if err != nil {
+		return err
+	}
+	err = h.Advertise()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+	case <-h.Done():
+		return h.Error()
+	}
+	h.StopAdvertising()
+	return ctx.Err()
+}
+
+// create an Eddystone-URL frame for the URL
+func eddystoneURLFrame(url string, txPower int) ([]byte, error) {
+	if txPower < -100 || txPower > 20 {
+		return nil, fmt.Errorf("invalid TX power %d", txPower)
+	}
+	scheme := -1
+	// the www. schemes come first in the list so they are matched first
+	for i, prefix := range eddystoneSchemes {
+		if strings.HasPrefix(url, prefix) {
+			scheme = i
+			url = url[len(prefix):]
+			break
+		}
+	}
+	if scheme < 0 {
+		return nil, fmt.Errorf("URL %q must start with http:// or https://", url)
+	}
+	frame := []byte{0x10, byte(int8(txPower)), byte(scheme)}
+	for len(url) > 0 {
+		expanded := false
+		// expansions ending with a / come first in the list so they are
+		// preferred over the ones without
+		for i, expansion := range eddystoneExpansions {
+			if strings.HasPrefix(url, expansion) {
+				frame = append(frame, byte(i))
+				url = url[len(expansion):]
+				expanded = true
+				break
+			}
+		}
+		if !expanded {
+			if url[0] <= 0x20 || url[0] >= 0x7f {
+				return nil, fmt.Errorf("invalid character %q in URL", url[0])
+			}
+			frame = append(frame, url[0])
+			url = url[1:]
+		}
+	}
+	if len(frame) > 20 {
+		return nil, fmt.Errorf("URL is too long, it must encode to at most 17 bytes")
+	}
+	return frame, nil
+}
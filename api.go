@@ -0,0 +1,457 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var errPairingNotSupported = errors.New("pairing is not supported by the BLE library")
+
+// handler to return the list of devices as JSON
+func apiDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	window, err := requestExpiry(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, withAddressTypes(devicesSeenWithin(window), r.URL.Query().Get("addresstype")))
+}
+
+// Adapters lists the adapters in use and the ones present in the system
+type Adapters struct {
+	Adapters  []AdapterStatus `json:"adapters"`
+	Available []string        `json:"available"`
+}
+
+// AdaptersRequest is the body of a request to switch adapters
+type AdaptersRequest struct {
+	Adapters []string `json:"adapters"`
+}
+
+// handler to show (GET) or switch (PUT) the adapters in use
+func apiAdapters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		req := AdaptersRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = switchAdapters(req.Adapters)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		saveState()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	adaptersMutex.Lock()
+	defer adaptersMutex.Unlock()
+	writeJSON(w, http.StatusOK, Adapters{Adapters: adapterStatuses(), Available: availableAdapters()})
+}
+
+// handler to show (GET) or change (PUT) the scan settings
+func apiScanSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		settings := currentScanSettings()
+		err := json.NewDecoder(r.Body).Decode(&settings)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = setScanSettings(settings)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		saveState()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, currentScanSettings())
+}
+
+// handler to show (GET) or change (PUT) the configuration
+func apiConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		c := currentConfig()
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = setConfig(c)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		saveState()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, currentConfig())
+}
+
+// Status is the state of the scanner and its adapters
+type Status struct {
+	Scanning              bool            `json:"scanning"`
+	Duration              float64         `json:"duration"`              // of each scan, in seconds
+	Uptime                float64         `json:"uptime"`                // in seconds
+	Devices               int             `json:"devices"`               // tracked
+	Distinct              int             `json:"distinct"`              // visible, counting rotating addresses once
+	ExposureNotifications int             `json:"exposurenotifications"` // visible, not counted in devices
+	LastAdvertisement     time.Time       `json:"lastadvertisement"`
+	Adapters              []AdapterStatus `json:"adapters"`
+}
+
+// when blueblue started
+var started = time.Now()
+
+// handler to show the state of the scanner and its adapters
+func showStatus(w http.ResponseWriter, r *http.Request) {
+	adaptersMutex.Lock()
+	statuses := adapterStatuses()
+	adaptersMutex.Unlock()
+	status := Status{
+		Scanning: !stop.Load(),
+		Duration: currentScanSettings().Duration,
+		Uptime:   time.Since(started).Seconds(),
+		Adapters: statuses,
+	}
+	for _, adapter := range statuses {
+		if adapter.LastAdvertisement.After(status.LastAdvertisement) {
+			status.LastAdvertisement = adapter.LastAdvertisement
+		}
+	}
+	mutex.RLock()
+	status.Devices = len(devices)
+	mutex.RUnlock()
+	status.Distinct = distinctDevices(deviceList())
+	status.ExposureNotifications = exposureNotificationsSince(time.Now().Add(-expiry()))
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handler to show (GET), start (POST) or stop (DELETE) advertising
+func apiAdvertising(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, currentAdvertising())
+	case http.MethodPost:
+		a := Advertising{}
+		err := json.NewDecoder(r.Body).Decode(&a)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = startAdvertising(a)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, currentAdvertising())
+	case http.MethodDelete:
+		if !endAdvertising() {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handler to list (GET) or add (POST) scan schedules
+func apiSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, scheduleList())
+	case http.MethodPost:
+		s := Schedule{}
+		err := json.NewDecoder(r.Body).Decode(&s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		schedule, err := addSchedule(s.Cron)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		saveState()
+		writeJSON(w, http.StatusCreated, schedule)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handler to remove (DELETE) a scan schedule, i.e. /api/v1/schedules/{id}
+func apiSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/schedules/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !removeSchedule(id) {
+		writeError(w, http.StatusNotFound, errors.New("schedule not found"))
+		return
+	}
+	saveState()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handler for requests about a single device, i.e. /api/v1/devices/{addr}/...
+func apiDevice(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/devices/"), "/")
+	address := path[0]
+	switch {
+	case len(path) == 2 && path[1] == "history":
+		apiHistory(w, r, address)
+	case len(path) == 2 && path[1] == "connect":
+		apiConnect(w, r, address)
+	case len(path) == 2 && path[1] == "pair":
+		apiPair(w, r, address)
+	case len(path) == 2 && path[1] == "identify":
+		apiIdentify(w, r, address)
+	case len(path) == 2 && path[1] == "services":
+		apiServices(w, r, address)
+	// /api/v1/devices/{addr}/services/{service}/characteristics/{characteristic}
+	case len(path) == 5 && path[1] == "services" && path[3] == "characteristics":
+		apiCharacteristic(w, r, address, path[2], path[4])
+	case len(path) == 6 && path[1] == "services" && path[3] == "characteristics" && path[5] == "notifications":
+		apiNotifications(w, r, address, path[2], path[4])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handler to return the advertisement history of a device
+func apiHistory(w http.ResponseWriter, r *http.Request, address string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	samples, ok := deviceHistory(address)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, samples)
+}
+
+// handler to connect to (POST) or disconnect from (DELETE) a device over GATT,
+// the connection timeout can be set with the timeout query parameter
+func apiConnect(w http.ResponseWriter, r *http.Request, address string) {
+	switch r.Method {
+	case http.MethodPost:
+		timeout := *connectTimeout
+		if t := r.URL.Query().Get("timeout"); t != "" {
+			var err error
+			timeout, err = time.ParseDuration(t)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		peer, err := connect(address, timeout)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, peer.Connection())
+	case http.MethodDelete:
+		if !disconnect(address) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handler to pair with a device; the BLE library has no Security Manager
+// implementation and rejects every pairing request, so neither pairing nor
+// bonding is possible yet
+func apiPair(w http.ResponseWriter, r *http.Request, address string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeError(w, http.StatusNotImplemented, errPairingNotSupported)
+}
+
+// handler to read the Device Information Service of a device and cache it
+// against the device
+func apiIdentify(w http.ResponseWriter, r *http.Request, address string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	peer, err := connect(address, *connectTimeout)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	info, err := peer.Identify()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	mutex.Lock()
+	if device, ok := devices[address]; ok {
+		device.Info = info
+		devices[address] = device
+	}
+	mutex.Unlock()
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handler to return the GATT services, characteristics and descriptors of a
+// device, connecting to it if needed; rediscovers them if refresh is set
+func apiServices(w http.ResponseWriter, r *http.Request, address string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	peer, err := connect(address, *connectTimeout)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	services, err := peer.Services(r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, services)
+}
+
+// WriteRequest is the body of a characteristic write, the value is given
+// either as hex or as text
+type WriteRequest struct {
+	Hex             string `json:"hex"`
+	Text            string `json:"text"`
+	WithoutResponse bool   `json:"withoutresponse"`
+}
+
+// handler to read (GET) or write (PUT) the value of a characteristic
+func apiCharacteristic(w http.ResponseWriter, r *http.Request, address, service, characteristic string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	peer, err := connect(address, *connectTimeout)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	c, err := peer.Characteristic(service, characteristic)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		req := WriteRequest{}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		value := []byte(req.Text)
+		if req.Hex != "" {
+			value, err = hex.DecodeString(strings.ReplaceAll(req.Hex, " ", ""))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		err = peer.Client.WriteCharacteristic(c, value, req.WithoutResponse)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	value, err := peer.Client.ReadLongCharacteristic(c)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newValue(c.UUID, value))
+}
+
+// handler to stream the notifications of a characteristic over websocket,
+// or as server-sent events if it's not a websocket request; subscribes to
+// indications instead if indicate is set
+func apiNotifications(w http.ResponseWriter, r *http.Request, address, service, characteristic string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	peer, err := connect(address, *connectTimeout)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	c, err := peer.Characteristic(service, characteristic)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	indicate := r.URL.Query().Get("indicate") == "true"
+	ch, err := peer.Subscribe(c, indicate)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	defer peer.Unsubscribe(c, indicate, ch)
+
+	if websocket.IsWebSocketUpgrade(r) {
+		streamNotificationsWebSocket(w, r, ch)
+	} else {
+		streamNotificationsSSE(w, r, ch)
+	}
+}
+
+// write out the data as JSON with the given status code
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		logger.Println("Cannot encode JSON:", err)
+	}
+}
+
+// write out the error as JSON with the given status code
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
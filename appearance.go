@@ -0,0 +1,136 @@
+package main
+
+// categories of the GAP Appearance, the top 10 bits of its value
+// [Assigned Numbers, 2.6]
+var appearanceCategories = map[uint16]string{
+	0x001: "Phone",
+	0x002: "Computer",
+	0x003: "Watch",
+	0x004: "Clock",
+	0x005: "Display",
+	0x006: "Remote Control",
+	0x007: "Eye-glasses",
+	0x008: "Tag",
+	0x009: "Keyring",
+	0x00a: "Media Player",
+	0x00b: "Barcode Scanner",
+	0x00c: "Thermometer",
+	0x00d: "Heart Rate Sensor",
+	0x00e: "Blood Pressure",
+	0x00f: "Human Interface Device",
+	0x010: "Glucose Meter",
+	0x011: "Running Walking Sensor",
+	0x012: "Cycling",
+	0x013: "Control Device",
+	0x014: "Network Device",
+	0x015: "Sensor",
+	0x016: "Light Fixtures",
+	0x017: "Fan",
+	0x018: "HVAC",
+	0x019: "Air Conditioning",
+	0x01a: "Humidifier",
+	0x01b: "Heating",
+	0x01c: "Access Control",
+	0x01d: "Motorized Device",
+	0x01e: "Power Device",
+	0x01f: "Light Source",
+	0x020: "Window Covering",
+	0x021: "Audio Sink",
+	0x022: "Audio Source",
+	0x023: "Motorized Vehicle",
+	0x024: "Domestic Appliance",
+	0x025: "Wearable Audio Device",
+	0x026: "Aircraft",
+	0x027: "AV Equipment",
+	0x028: "Display Equipment",
+	0x029: "Hearing Aid",
+	0x02a: "Gaming",
+	0x02b: "Signage",
+	0x031: "Pulse Oximeter",
+	0x032: "Weight Scale",
+	0x033: "Personal Mobility Device",
+	0x034: "Continuous Glucose Monitor",
+	0x035: "Insulin Pump",
+	0x036: "Medication Delivery",
+	0x037: "Spirometer",
+	0x051: "Outdoor Sports Activity",
+}
+
+// subcategories of the GAP Appearance, by the whole value
+var appearanceSubcategories = map[uint16]string{
+	0x0081: "Desktop Workstation",
+	0x0082: "Server-class Computer",
+	0x0083: "Laptop",
+	0x0084: "Handheld PC/PDA",
+	0x0085: "Palm-size PC/PDA",
+	0x0086: "Wearable Computer",
+	0x0087: "Tablet",
+	0x0088: "Docking Station",
+	0x0089: "All in One",
+	0x008a: "Blade Server",
+	0x008b: "Convertible",
+	0x008c: "Detachable",
+	0x008d: "IoT Gateway",
+	0x008e: "Mini PC",
+	0x008f: "Stick PC",
+	0x00c1: "Sports Watch",
+	0x00c2: "Smartwatch",
+	0x0301: "Ear Thermometer",
+	0x0341: "Heart Rate Belt",
+	0x0381: "Arm Blood Pressure",
+	0x0382: "Wrist Blood Pressure",
+	0x03c1: "Keyboard",
+	0x03c2: "Mouse",
+	0x03c3: "Joystick",
+	0x03c4: "Gamepad",
+	0x03c5: "Digitizer Tablet",
+	0x03c6: "Card Reader",
+	0x03c7: "Digital Pen",
+	0x03c8: "Barcode Scanner",
+	0x03c9: "Touchpad",
+	0x03ca: "Presentation Remote",
+	0x0441: "In-Shoe Running Walking Sensor",
+	0x0442: "On-Shoe Running Walking Sensor",
+	0x0443: "On-Hip Running Walking Sensor",
+	0x0481: "Cycling Computer",
+	0x0482: "Speed Sensor",
+	0x0483: "Cadence Sensor",
+	0x0484: "Power Sensor",
+	0x0485: "Speed and Cadence Sensor",
+	0x0841: "Standalone Speaker",
+	0x0842: "Soundbar",
+	0x0843: "Bookshelf Speaker",
+	0x0844: "Standmounted Speaker",
+	0x0845: "Speakerphone",
+	0x0881: "Microphone",
+	0x0882: "Alarm",
+	0x0883: "Bell",
+	0x0884: "Horn",
+	0x0885: "Broadcasting Device",
+	0x0941: "Earbud",
+	0x0942: "Headset",
+	0x0943: "Headphones",
+	0x0944: "Neck Band",
+	0x0a41: "In-ear Hearing Aid",
+	0x0a42: "Behind-ear Hearing Aid",
+	0x0a43: "Cochlear Implant",
+	0x0a81: "Home Video Game Console",
+	0x0a82: "Portable Handheld Console",
+	0x0c41: "Fingertip Pulse Oximeter",
+	0x0c42: "Wrist Worn Pulse Oximeter",
+	0x0cc1: "Powered Wheelchair",
+	0x0cc2: "Mobility Scooter",
+	0x1441: "Location Display",
+	0x1442: "Location and Navigation Display",
+	0x1443: "Location Pod",
+	0x1444: "Location and Navigation Pod",
+}
+
+// the name of the appearance, its subcategory if it's known or otherwise
+// its category, "" if it's unknown
+func appearanceName(appearance uint16) string {
+	if name, ok := appearanceSubcategories[appearance]; ok {
+		return name
+	}
+	return appearanceCategories[appearance>>6]
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// roles, viewers can see devices and admins can also change things
+const (
+	RoleViewer = "viewer"
+	RoleAdmin  = "admin"
+)
+
+// the role of a user, everyone who isn't a viewer is an admin
+func userRole(user string) string {
+	for _, viewer := range strings.Split(*viewers, ",") {
+		if viewer == user {
+			return RoleViewer
+		}
+	}
+	return RoleAdmin
+}
+
+// check if the token is one of the comma-separated tokens
+func tokenIn(token, tokens string) bool {
+	if token == "" || tokens == "" {
+		return false
+	}
+	for _, t := range strings.Split(tokens, ",") {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// the role of the request's API token, given as a bearer token or in the
+// token query parameter for clients that can't set headers
+func tokenRole(r *http.Request) (role string, ok bool) {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	switch {
+	case tokenIn(token, *apiTokens):
+		return RoleAdmin, true
+	case tokenIn(token, *viewerTokens):
+		return RoleViewer, true
+	}
+	return "", false
+}
+
+// the role of the request's basic auth user
+func basicAuthRole(r *http.Request) (role string, ok bool) {
+	user, password, ok := r.BasicAuth()
+	if !ok || !validCredentials(user, password) {
+		return "", false
+	}
+	return userRole(user), true
+}
+
+// the pages and endpoints viewers can look at, those that only show devices
+// and status. Everything else, like the configuration, connecting to
+// devices, downloading captures and debugging, is for admins
+var viewerPaths = map[string]bool{
+	"/":                      true,
+	"/devices":               true,
+	"/device":                true,
+	"/status":                true,
+	"/ws":                    true,
+	"/events":                true,
+	"/metrics":               true,
+	"/api/v1/devices":        true,
+	"/api/v1/devices/export": true,
+	"/api/v1/devices/groups": true,
+	"/api/v1/presence":       true,
+	"/api/v1/version":        true,
+	"/api/v1/openapi.json":   true,
+	"/api/v1/status":         true,
+}
+
+// check if the role may make the request, viewers can only look at devices
+// and status, and log out
+func allowed(role string, r *http.Request) bool {
+	if role == RoleAdmin || r.URL.Path == "/logout" {
+		return true
+	}
+	// GraphQL queries are posted as well as got, and there are only queries
+	// and subscriptions of devices
+	if r.URL.Path == "/graphql" {
+		return r.Method == http.MethodGet || r.Method == http.MethodPost
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if viewerPaths[r.URL.Path] {
+		return true
+	}
+	// a device and its history, but not its services, which connects to it
+	if strings.HasPrefix(r.URL.Path, "/api/v1/devices/") {
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/devices/"), "/")
+		return len(path) == 1 || len(path) == 2 && path[1] == "history"
+	}
+	return false
+}
+
+// only let authenticated requests through to the handler, if authentication
+// is configured. Pages take a login session and the JSON API takes API
+// tokens. Both take basic auth, and the API takes sessions so the pages can
+// call it
+func authenticate(handler http.Handler) http.Handler {
+	logins := *basicAuth != "" || *htpasswdPath != "" || hasConfigUsers()
+	if *apiTokens == "" && *viewerTokens == "" && !logins {
+		return handler
+	}
+	if logins {
+		go forgetSessions()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" ||
+			strings.HasPrefix(r.URL.Path, "/public/") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		role, ok := basicAuthRole(r)
+		if session := requestSession(r); session != nil {
+			if !validCSRF(r, session) {
+				writeError(w, http.StatusForbidden, errors.New("missing or invalid CSRF token"))
+				return
+			}
+			role, ok = session.Role, true
+		} else if ok && !sameOrigin(r) {
+			writeError(w, http.StatusForbidden, errors.New("basic auth can't be used by other sites"))
+			return
+		} else if !ok && api(r) {
+			role, ok = tokenRole(r)
+		}
+		if !ok {
+			if logins && !api(r) {
+				http.Redirect(w, r, *basePath+"/login", http.StatusSeeOther)
+				return
+			}
+			writeError(w, http.StatusUnauthorized, errors.New("not authenticated"))
+			return
+		}
+		if !allowed(role, r) {
+			writeError(w, http.StatusForbidden, errors.New("only admins can do this"))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// check if the request is for the JSON API, as opposed to the pages
+func api(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/debug/") ||
+		r.URL.Path == "/status" || r.URL.Path == "/metrics" || r.URL.Path == "/ws" || r.URL.Path == "/events" ||
+		r.URL.Path == "/graphql"
+}
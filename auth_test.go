@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestViewerAllowed(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/", true},
+		{"GET", "/devices", true},
+		{"GET", "/api/v1/devices", true},
+		{"GET", "/api/v1/devices/aa:bb:cc:dd:ee:ff", true},
+		{"GET", "/api/v1/devices/aa:bb:cc:dd:ee:ff/history", true},
+		{"GET", "/api/v1/status", true},
+		{"POST", "/logout", true},
+		{"GET", "/graphql", true},
+		{"POST", "/graphql", true},
+		{"PUT", "/graphql", false},
+		{"DELETE", "/graphql", false},
+		{"GET", "/api/v1/config", false},
+		{"GET", "/api/v1/adapters", false},
+		{"GET", "/api/v1/scan/settings", false},
+		{"GET", "/api/v1/schedules", false},
+		{"GET", "/api/v1/schedules/1", false},
+		{"GET", "/api/v1/devices/aa:bb:cc:dd:ee:ff/services", false},
+		{"GET", "/api/v1/devices/aa:bb:cc:dd:ee:ff/services/180f/characteristics/2a19", false},
+		{"GET", "/api/v1/devices/aa:bb:cc:dd:ee:ff/services/180f/characteristics/2a19/notifications", false},
+		{"GET", "/api/v1/capture/download", false},
+		{"GET", "/api/v1/capture", false},
+		{"GET", "/start", false},
+		{"GET", "/debug/vars", false},
+		{"PUT", "/api/v1/config", false},
+		{"POST", "/api/v1/devices/aa:bb:cc:dd:ee:ff/identify", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if got := allowed(RoleViewer, r); got != test.want {
+			t.Errorf("viewer %s %s allowed = %v, want %v", test.method, test.path, got, test.want)
+		}
+		if !allowed(RoleAdmin, r) {
+			t.Errorf("admin %s %s not allowed", test.method, test.path)
+		}
+	}
+}
+
+// viewers can post to /graphql because it can't change anything
+func TestGraphQLHasNoMutations(t *testing.T) {
+	schema, err := graphqlSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.MutationType() != nil {
+		t.Error("the GraphQL schema has mutations, which viewers can post")
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		method, path, site, origin string
+		want                       bool
+	}{
+		{"POST", "/api/v1/advertise", "", "", true},
+		{"POST", "/api/v1/advertise", "same-origin", "", true},
+		{"POST", "/api/v1/advertise", "cross-site", "", false},
+		{"POST", "/api/v1/advertise", "same-site", "http://example.com", false},
+		{"POST", "/api/v1/advertise", "", "http://example.com", true},
+		{"POST", "/api/v1/advertise", "", "http://attacker.example", false},
+		{"GET", "/start", "cross-site", "", false},
+		{"GET", "/devices", "cross-site", "", true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if test.site != "" {
+			r.Header.Set("Sec-Fetch-Site", test.site)
+		}
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if got := sameOrigin(r); got != test.want {
+			t.Errorf("%s %s from %q %q same origin = %v, want %v", test.method, test.path, test.site, test.origin, got, test.want)
+		}
+	}
+}
+
+func TestReadUsers(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("changeme"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "blueblue.json")
+	err = os.WriteFile(path, []byte(`{"expiry": 60, "users": {"admin": "`+string(hash)+`"}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = readUsers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { configUsers = map[string][]byte{} }()
+	if !hasConfigUsers() || !validCredentials("admin", "changeme") {
+		t.Error("user in the configuration file can't log in")
+	}
+	if validCredentials("admin", "wrong") || validCredentials("guest", "changeme") {
+		t.Error("wrong credentials are valid")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// EventBatteryLow is published when a device's battery level drops to or
+// below the low battery threshold
+const EventBatteryLow = "battery_low"
+
+// Battery is the last battery level read from a device
+type Battery struct {
+	Level int       `json:"level"` // in percent
+	Read  time.Time `json:"read"`
+}
+
+// check if the advertisement is from a connectable device advertising the
+// Battery Service
+func advertisesBattery(a ble.Advertisement) bool {
+	if !a.Connectable() {
+		return false
+	}
+	for _, u := range a.Services() {
+		if u.Equal(ble.BatteryUUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// periodically connect to the visible devices with a Battery Service and
+// read their battery level
+func pollBatteries() {
+	for range time.Tick(*batteryPoll) {
+		for _, device := range deviceList() {
+			if !device.batteryService {
+				continue
+			}
+			level, err := readBattery(device.Address)
+			if err != nil {
+				logger.Println("Cannot read battery level of", device.Address, err)
+				continue
+			}
+			updateBattery(device.Address, level)
+		}
+	}
+}
+
+// read the battery level of the device, disconnecting afterwards unless
+// it was already connected
+func readBattery(address string) (int, error) {
+	connected := isConnected(address)
+	peer, err := connect(address, *connectTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if !connected {
+		defer disconnect(address)
+	}
+	c, err := peer.Characteristic("180f", "2a19")
+	if err != nil {
+		return 0, err
+	}
+	value, err := peer.Client.ReadCharacteristic(c)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) == 0 {
+		return 0, nil
+	}
+	return int(value[0]), nil
+}
+
+// record the battery level against the device and raise an alert if it
+// just dropped below the threshold
+func updateBattery(address string, level int) {
+	mutex.Lock()
+	device, ok := devices[address]
+	if !ok {
+		mutex.Unlock()
+		return
+	}
+	low := currentConfig().BatteryLow
+	wasLow := device.Battery != nil && device.Battery.Level <= low
+	device.Battery = &Battery{Level: level, Read: time.Now()}
+	devices[address] = device
+	mutex.Unlock()
+
+	if level <= low && !wasLow {
+		alertBattery(device)
+	}
+}
+
+// publish the low battery event and run the alert command, if there is one,
+// with the device address and battery level as arguments
+func alertBattery(device Device) {
+	logger.Println("Low battery on", device.Address, device.Battery.Level, "%")
+	broker.Publish(Event{Type: EventBatteryLow, Device: device})
+	if *batteryAlert == "" || quiet() {
+		return
+	}
+	runAlert("battery", *batteryAlert, device.Address, strconv.Itoa(device.Battery.Level))
+}
+
+// how long an alert command can run before it's killed
+const alertTimeout = 30 * time.Second
+
+// run the alert command in the background, so a slow command doesn't hold
+// up whatever raised the alert, killing it if it runs for too long
+func runAlert(alert, command string, args ...string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+		defer cancel()
+		err := exec.CommandContext(ctx, command, args...).Run()
+		if err != nil {
+			logger.Println("Cannot run "+alert+" alert command:", err)
+		}
+	}()
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunAlertDoesntWait(t *testing.T) {
+	start := time.Now()
+	runAlert("test", "sleep", "1")
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("waited %v for the alert command", waited)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/sausheong/ble"
+)
+
+// IBeacon is a decoded Apple iBeacon frame
+type IBeacon struct {
+	UUID    string `json:"uuid"`
+	Major   uint16 `json:"major"`
+	Minor   uint16 `json:"minor"`
+	TxPower int    `json:"txpower"`
+}
+
+// decode an iBeacon frame from the manufacturer data, returns nil if it's
+// not an iBeacon
+func decodeIBeacon(data []byte) *IBeacon {
+	// Apple company ID (0x004c), type 0x02 and length 0x15
+	if len(data) < 25 || data[0] != 0x4c || data[1] != 0x00 || data[2] != 0x02 || data[3] != 0x15 {
+		return nil
+	}
+	return &IBeacon{
+		UUID:    formatUUID(data[4:20]),
+		Major:   binary.BigEndian.Uint16(data[20:22]),
+		Minor:   binary.BigEndian.Uint16(data[22:24]),
+		TxPower: int(int8(data[24])),
+	}
+}
+
+// format 16 bytes as a UUID string
+func formatUUID(b []byte) string {
+	s := hex.EncodeToString(b)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// AltBeacon is a decoded AltBeacon frame
+type AltBeacon struct {
+	Manufacturer  uint16 `json:"manufacturer"`
+	BeaconID      string `json:"beaconid"`
+	ReferenceRSSI int    `json:"referencerssi"`
+	Reserved      uint8  `json:"reserved"`
+}
+
+// decode an AltBeacon frame from the manufacturer data, returns nil if it's
+// not an AltBeacon
+func decodeAltBeacon(data []byte) *AltBeacon {
+	// any company ID followed by the beacon code 0xbeac
+	if len(data) < 26 || data[2] != 0xbe || data[3] != 0xac {
+		return nil
+	}
+	return &AltBeacon{
+		Manufacturer:  binary.LittleEndian.Uint16(data[0:2]),
+		BeaconID:      hex.EncodeToString(data[4:24]),
+		ReferenceRSSI: int(int8(data[24])),
+		Reserved:      data[25],
+	}
+}
+
+// Eddystone holds the decoded frames of an Eddystone beacon; a beacon
+// usually interleaves several frame types so they are merged as they arrive
+type Eddystone struct {
+	UID *EddystoneUID `json:"uid,omitempty"`
+	URL string        `json:"url,omitempty"`
+	TLM *EddystoneTLM `json:"tlm,omitempty"`
+	EID string        `json:"eid,omitempty"`
+	// calibrated TX power at 0m, from the UID, URL or EID frame
+	TxPower int `json:"txpower"`
+}
+
+// EddystoneUID is the beacon ID of an Eddystone-UID frame
+type EddystoneUID struct {
+	Namespace string `json:"namespace"`
+	Instance  string `json:"instance"`
+}
+
+// EddystoneTLM is the telemetry of an unencrypted Eddystone-TLM frame
+type EddystoneTLM struct {
+	BatteryVoltage int     `json:"batteryvoltage"` // in mV
+	Temperature    float64 `json:"temperature"`    // in degrees Celsius
+	AdvCount       uint32  `json:"advcount"`
+	Uptime         float64 `json:"uptime"` // in seconds
+}
+
+// Eddystone service UUID
+var eddystoneUUID = ble.UUID16(0xfeaa)
+
+// Eddystone-URL scheme prefixes
+var eddystoneSchemes = []string{"http://www.", "https://www.", "http://", "https://"}
+
+// Eddystone-URL expansion codes
+var eddystoneExpansions = []string{
+	".com/", ".org/", ".edu/", ".net/", ".info/", ".biz/", ".gov/",
+	".com", ".org", ".edu", ".net", ".info", ".biz", ".gov",
+}
+
+// decode an Eddystone frame from the service data and merge it into the
+// previously decoded frames, returns the previous frames if there is no
+// Eddystone service data
+func decodeEddystone(serviceData []ble.ServiceData, previous *Eddystone) *Eddystone {
+	var data []byte
+	for _, sd := range serviceData {
+		if sd.UUID.Equal(eddystoneUUID) {
+			data = sd.Data
+			break
+		}
+	}
+	if len(data) < 2 {
+		return previous
+	}
+	eddystone := &Eddystone{}
+	if previous != nil {
+		*eddystone = *previous
+	}
+	switch data[0] {
+	case 0x00: // UID
+		if len(data) < 18 {
+			return previous
+		}
+		eddystone.TxPower = int(int8(data[1]))
+		eddystone.UID = &EddystoneUID{
+			Namespace: hex.EncodeToString(data[2:12]),
+			Instance:  hex.EncodeToString(data[12:18]),
+		}
+	case 0x10: // URL
+		if len(data) < 4 || int(data[2]) >= len(eddystoneSchemes) {
+			return previous
+		}
+		eddystone.TxPower = int(int8(data[1]))
+		eddystone.URL = decodeEddystoneURL(data[2], data[3:])
+	case 0x20: // TLM
+		if len(data) < 14 || data[1] != 0x00 {
+			return previous
+		}
+		eddystone.TLM = &EddystoneTLM{
+			BatteryVoltage: int(binary.BigEndian.Uint16(data[2:4])),
+			Temperature:    float64(int16(binary.BigEndian.Uint16(data[4:6]))) / 256,
+			AdvCount:       binary.BigEndian.Uint32(data[6:10]),
+			Uptime:         float64(binary.BigEndian.Uint32(data[10:14])) / 10,
+		}
+	case 0x30: // EID
+		if len(data) < 10 {
+			return previous
+		}
+		eddystone.TxPower = int(int8(data[1]))
+		eddystone.EID = hex.EncodeToString(data[2:10])
+	default:
+		return previous
+	}
+	return eddystone
+}
+
+// expand an encoded Eddystone URL
+func decodeEddystoneURL(scheme byte, encoded []byte) string {
+	url := eddystoneSchemes[scheme]
+	for _, b := range encoded {
+		if int(b) < len(eddystoneExpansions) {
+			url += eddystoneExpansions[b]
+		} else {
+			url += string(rune(b))
+		}
+	}
+	return url
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/sausheong/ble"
+)
+
+// the BlueZ D-Bus names used
+const (
+	bluezService    = "org.bluez"
+	bluezAdapter1   = "org.bluez.Adapter1"
+	bluezDevice1    = "org.bluez.Device1"
+	dbusProperties  = "org.freedesktop.DBus.Properties"
+	dbusObjects     = "org.freedesktop.DBus.ObjectManager"
+	bluetoothBaseID = "-0000-1000-8000-00805f9b34fb"
+)
+
+// bluezScanner scans with BlueZ over D-Bus, sharing the adapter with
+// bluetoothd and everything else using it instead of taking it over. BlueZ
+// doesn't give the raw advertisements, so they are made up from the device
+// properties it does give
+type bluezScanner struct {
+	conn      *dbus.Conn
+	adapter   dbus.BusObject
+	name      string
+	whitelist map[string]bool
+	// the properties of the devices BlueZ knows, by object path
+	devices map[dbus.ObjectPath]map[string]dbus.Variant
+}
+
+// the name and BlueZ adapter of a bluez scanner, bluez for hci0 or
+// bluez:hci1 for another adapter
+func bluezAdapterName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == ScannerBlueZ {
+		return "hci0", true
+	}
+	if !strings.HasPrefix(name, ScannerBlueZ+":") {
+		return "", false
+	}
+	return strings.TrimPrefix(name, ScannerBlueZ+":"), true
+}
+
+// connect to the system bus and find the adapter
+func openBlueZ(name string) (Scanner, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to D-Bus: %v", err)
+	}
+	adapter := conn.Object(bluezService, dbus.ObjectPath("/org/bluez/"+name))
+	_, err = adapter.GetProperty(bluezAdapter1 + ".Address")
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("can't open %s with BlueZ: %w", name, errNotPresent)
+	}
+	return &bluezScanner{conn: conn, adapter: adapter, name: name}, nil
+}
+
+// BlueZ picks the scan parameters itself, so only the whitelist is applied,
+// by the scanner instead of the controller
+func (b *bluezScanner) Configure(s ScanSettings) error {
+	b.whitelist = map[string]bool{}
+	for _, address := range s.Whitelist {
+		b.whitelist[strings.ToLower(address)] = true
+	}
+	return nil
+}
+
+// discover LE devices until the context is done, reporting a device when it
+// turns up and whenever its RSSI or advertised data changes. Without
+// duplicates, each device is reported once per scan, like the controller does
+func (b *bluezScanner) Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error {
+	path := b.adapter.Path()
+	signals := make(chan *dbus.Signal, 64)
+	b.conn.Signal(signals)
+	defer b.conn.RemoveSignal(signals)
+	matches := [][]dbus.MatchOption{
+		{dbus.WithMatchInterface(dbusObjects), dbus.WithMatchMember("InterfacesAdded")},
+		{dbus.WithMatchInterface(dbusObjects), dbus.WithMatchMember("InterfacesRemoved")},
+		{dbus.WithMatchInterface(dbusProperties), dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchPathNamespace(path)},
+	}
+	for _, match := range matches {
+		err := b.conn.AddMatchSignal(match...)
+		if err != nil {
+			return err
+		}
+		defer b.conn.RemoveMatchSignal(match...)
+	}
+	err := b.loadDevices()
+	if err != nil {
+		return err
+	}
+	filter := map[string]interface{}{"Transport": "le", "DuplicateData": duplicates}
+	err = b.adapter.Call(bluezAdapter1+".SetDiscoveryFilter", 0, filter).Err
+	if err != nil {
+		return err
+	}
+	err = b.adapter.Call(bluezAdapter1+".StartDiscovery", 0).Err
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := b.adapter.Call(bluezAdapter1+".StopDiscovery", 0).Err
+		if err != nil {
+			logger.Println("Cannot stop BlueZ discovery on", b.name, err)
+		}
+	}()
+
+	reported := map[dbus.ObjectPath]bool{}
+	report := func(device dbus.ObjectPath) {
+		a := bluezAdvertisement(b.devices[device])
+		if a == nil {
+			return
+		}
+		address := a.Addr().String()
+		if (len(b.whitelist) > 0 && !b.whitelist[address]) || (!duplicates && reported[device]) {
+			return
+		}
+		reported[device] = true
+		handler(a)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case signal, ok := <-signals:
+			if !ok {
+				return fmt.Errorf("lost the D-Bus connection")
+			}
+			switch signal.Name {
+			case dbusObjects + ".InterfacesAdded":
+				var device dbus.ObjectPath
+				var interfaces map[string]map[string]dbus.Variant
+				if dbus.Store(signal.Body, &device, &interfaces) != nil || !strings.HasPrefix(string(device), string(path)+"/") {
+					continue
+				}
+				if properties, ok := interfaces[bluezDevice1]; ok {
+					b.devices[device] = properties
+					report(device)
+				}
+			case dbusObjects + ".InterfacesRemoved":
+				var device dbus.ObjectPath
+				var interfaces []string
+				if dbus.Store(signal.Body, &device, &interfaces) == nil {
+					delete(b.devices, device)
+				}
+			case dbusProperties + ".PropertiesChanged":
+				var iface string
+				var changed map[string]dbus.Variant
+				var invalidated []string
+				if dbus.Store(signal.Body, &iface, &changed, &invalidated) != nil || iface != bluezDevice1 {
+					continue
+				}
+				properties, ok := b.devices[signal.Path]
+				if !ok {
+					properties = map[string]dbus.Variant{}
+					b.devices[signal.Path] = properties
+				}
+				for name, value := range changed {
+					properties[name] = value
+				}
+				for _, name := range invalidated {
+					delete(properties, name)
+				}
+				// a new advertisement changes the RSSI or the data
+				_, rssi := changed["RSSI"]
+				_, manufacturer := changed["ManufacturerData"]
+				_, service := changed["ServiceData"]
+				if rssi || manufacturer || service {
+					report(signal.Path)
+				}
+			}
+		}
+	}
+}
+
+// load the properties of the devices BlueZ already knows on the adapter
+func (b *bluezScanner) loadDevices() error {
+	objects := map[dbus.ObjectPath]map[string]map[string]dbus.Variant{}
+	err := b.conn.Object(bluezService, "/").Call(dbusObjects+".GetManagedObjects", 0).Store(&objects)
+	if err != nil {
+		return err
+	}
+	b.devices = map[dbus.ObjectPath]map[string]dbus.Variant{}
+	for path, interfaces := range objects {
+		if properties, ok := interfaces[bluezDevice1]; ok && strings.HasPrefix(string(path), string(b.adapter.Path())+"/") {
+			b.devices[path] = properties
+		}
+	}
+	return nil
+}
+
+// make up an advertisement from the device properties BlueZ gives, with the
+// name, services, TX power, manufacturer data and service data as AD
+// structures, or nil if the device hasn't been heard in this discovery
+func bluezAdvertisement(properties map[string]dbus.Variant) *replayedAdvertisement {
+	var rssi int16
+	if bluezProperty(properties, "RSSI", &rssi) != nil {
+		return nil
+	}
+	var addressString, addressTypeString string
+	bluezProperty(properties, "Address", &addressString)
+	bluezProperty(properties, "AddressType", &addressTypeString)
+	mac, err := net.ParseMAC(addressString)
+	if err != nil || len(mac) != 6 {
+		return nil
+	}
+	var address [6]byte
+	copy(address[:], mac)
+	addressType := byte(addressPublic)
+	if addressTypeString == "random" {
+		addressType = addressRandom
+	}
+
+	structures := [][]byte{}
+	var name string
+	if bluezProperty(properties, "Name", &name) == nil && name != "" {
+		structures = append(structures, adStructure(0x09, []byte(name)...))
+	}
+	var uuids []string
+	bluezProperty(properties, "UUIDs", &uuids)
+	var short, long []byte
+	for _, uuid := range uuids {
+		if u, ok := bluetoothUUID16(uuid); ok {
+			short = binary.LittleEndian.AppendUint16(short, u)
+		} else if u, err := ble.Parse(uuid); err == nil && u.Len() == 16 {
+			long = append(long, u...)
+		}
+	}
+	if len(short) > 0 {
+		structures = append(structures, adStructure(0x03, short...))
+	}
+	if len(long) > 0 {
+		structures = append(structures, adStructure(0x07, long...))
+	}
+	var power int16
+	if bluezProperty(properties, "TxPower", &power) == nil {
+		structures = append(structures, adStructure(0x0a, byte(int8(power))))
+	}
+	var manufacturerData map[uint16]dbus.Variant
+	bluezProperty(properties, "ManufacturerData", &manufacturerData)
+	companies := []int{}
+	for company := range manufacturerData {
+		companies = append(companies, int(company))
+	}
+	sort.Ints(companies)
+	for _, company := range companies {
+		var data []byte
+		manufacturerData[uint16(company)].Store(&data)
+		value := binary.LittleEndian.AppendUint16(nil, uint16(company))
+		structures = append(structures, adStructure(0xff, append(value, data...)...))
+	}
+	var serviceData map[string]dbus.Variant
+	bluezProperty(properties, "ServiceData", &serviceData)
+	services := []string{}
+	for uuid := range serviceData {
+		services = append(services, uuid)
+	}
+	sort.Strings(services)
+	for _, uuid := range services {
+		var data []byte
+		serviceData[uuid].Store(&data)
+		if u, ok := bluetoothUUID16(uuid); ok {
+			structures = append(structures, adStructure(0x16, append(binary.LittleEndian.AppendUint16(nil, u), data...)...))
+		} else if u, err := ble.Parse(uuid); err == nil && u.Len() == 16 {
+			structures = append(structures, adStructure(0x21, append(append([]byte{}, u...), data...)...))
+		}
+	}
+
+	// as much as fits in an advertising report, which is scannable but not
+	// connectable since BlueZ doesn't say whether the device is
+	data := []byte{}
+	for _, s := range structures {
+		if len(s) > 255 || len(data)+len(s) > 255 {
+			continue
+		}
+		data = append(data, s...)
+	}
+	return &replayedAdvertisement{e: advertisingReport(advScanInd, addressType, address, data, int(rssi))}
+}
+
+// store the device property with the given name, if the device has it
+func bluezProperty(properties map[string]dbus.Variant, name string, value interface{}) error {
+	v, ok := properties[name]
+	if !ok || v.Value() == nil {
+		return fmt.Errorf("no %s property", name)
+	}
+	return v.Store(value)
+}
+
+// the 16-bit UUID of a UUID made from the Bluetooth base UUID, as BlueZ
+// writes them
+func bluetoothUUID16(uuid string) (uint16, bool) {
+	uuid = strings.ToLower(uuid)
+	if len(uuid) != 36 || !strings.HasPrefix(uuid, "0000") || !strings.HasSuffix(uuid, bluetoothBaseID) {
+		return 0, false
+	}
+	u, err := strconv.ParseUint(uuid[4:8], 16, 16)
+	return uint16(u), err == nil
+}
+
+// check if the adapter has gone away from BlueZ, or bluetoothd has stopped
+func (b *bluezScanner) Unplugged() bool {
+	_, err := b.adapter.GetProperty(bluezAdapter1 + ".Address")
+	return err != nil
+}
+
+func (b *bluezScanner) Address() string {
+	v, err := b.adapter.GetProperty(bluezAdapter1 + ".Address")
+	if err != nil {
+		return ""
+	}
+	var address string
+	v.Store(&address)
+	return address
+}
+
+func (b *bluezScanner) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"math"
+
+	"github.com/sausheong/ble"
+)
+
+// BTHome service UUID
+var bthomeUUID = ble.UUID16(0xfcd2)
+
+// a kind of BTHome object, its name, size in bytes, whether it's signed and
+// the factor to scale its value with
+type bthomeObject struct {
+	name   string
+	size   int
+	signed bool
+	factor float64
+}
+
+// the BTHome v2 objects by ID, which are needed to know how long each one is
+// even when its value isn't used. Text and raw objects are prefixed with
+// their length, which is handled separately
+var bthomeObjects = map[byte]bthomeObject{
+	0x00: {"packet", 1, false, 1},
+	0x01: {"battery", 1, false, 1},
+	0x02: {"temperature", 2, true, 0.01},
+	0x03: {"humidity", 2, false, 0.01},
+	0x04: {"pressure", 3, false, 0.01},
+	0x05: {"illuminance", 3, false, 0.01},
+	0x06: {"mass", 2, false, 0.01},
+	0x07: {"masslb", 2, false, 0.01},
+	0x08: {"dewpoint", 2, true, 0.01},
+	0x09: {"count", 1, false, 1},
+	0x0a: {"energy", 3, false, 0.001},
+	0x0b: {"power", 3, false, 0.01},
+	0x0c: {"voltage", 2, false, 0.001},
+	0x0d: {"pm25", 2, false, 1},
+	0x0e: {"pm10", 2, false, 1},
+	0x0f: {"generic", 1, false, 1},
+	0x10: {"powered", 1, false, 1},
+	0x11: {"opening", 1, false, 1},
+	0x12: {"co2", 2, false, 1},
+	0x13: {"tvoc", 2, false, 1},
+	0x14: {"moisture", 2, false, 0.01},
+	0x15: {"batterylow", 1, false, 1},
+	0x16: {"batterycharging", 1, false, 1},
+	0x17: {"carbonmonoxide", 1, false, 1},
+	0x18: {"cold", 1, false, 1},
+	0x19: {"connectivity", 1, false, 1},
+	0x1a: {"door", 1, false, 1},
+	0x1b: {"garagedoor", 1, false, 1},
+	0x1c: {"gas", 1, false, 1},
+	0x1d: {"heat", 1, false, 1},
+	0x1e: {"light", 1, false, 1},
+	0x1f: {"lock", 1, false, 1},
+	0x20: {"wet", 1, false, 1},
+	0x21: {"motion", 1, false, 1},
+	0x22: {"moving", 1, false, 1},
+	0x23: {"occupancy", 1, false, 1},
+	0x24: {"plug", 1, false, 1},
+	0x25: {"presence", 1, false, 1},
+	0x26: {"problem", 1, false, 1},
+	0x27: {"running", 1, false, 1},
+	0x28: {"safety", 1, false, 1},
+	0x29: {"smoke", 1, false, 1},
+	0x2a: {"sound", 1, false, 1},
+	0x2b: {"tamper", 1, false, 1},
+	0x2c: {"vibration", 1, false, 1},
+	0x2d: {"window", 1, false, 1},
+	0x2e: {"humidity", 1, false, 1},
+	0x2f: {"moisture", 1, false, 1},
+	0x3a: {"button", 1, false, 1},
+	0x3c: {"dimmer", 2, false, 1},
+	0x3d: {"count", 2, false, 1},
+	0x3e: {"count", 4, false, 1},
+	0x3f: {"rotation", 2, true, 0.1},
+	0x40: {"distance", 2, false, 0.001},
+	0x41: {"distance", 2, false, 0.1},
+	0x42: {"duration", 3, false, 0.001},
+	0x43: {"current", 2, false, 0.001},
+	0x44: {"speed", 2, false, 0.01},
+	0x45: {"temperature", 2, true, 0.1},
+	0x46: {"uvindex", 1, false, 0.1},
+	0x47: {"volume", 2, false, 0.1},
+	0x48: {"volume", 2, false, 0.001},
+	0x49: {"flowrate", 2, false, 0.001},
+	0x4a: {"voltage", 2, false, 0.1},
+	0x4b: {"gasvolume", 3, false, 0.001},
+	0x4c: {"gasvolume", 4, false, 0.001},
+	0x4d: {"energy", 4, false, 0.001},
+	0x4e: {"volume", 4, false, 0.001},
+	0x4f: {"water", 4, false, 0.001},
+	0x50: {"timestamp", 4, false, 1},
+	0x51: {"acceleration", 2, false, 0.001},
+	0x52: {"gyroscope", 2, false, 0.001},
+	0x55: {"volumestorage", 4, false, 0.001},
+	0x56: {"conductivity", 2, false, 1},
+	0x57: {"temperature", 1, true, 1},
+	0x58: {"temperature", 1, true, 0.35},
+	0x59: {"count", 1, true, 1},
+	0x5a: {"count", 2, true, 1},
+	0x5b: {"count", 4, true, 1},
+	0x5c: {"power", 4, true, 0.01},
+	0x5d: {"current", 2, true, 0.001},
+	0xf0: {"devicetype", 2, false, 1},
+	0xf1: {"firmware", 4, false, 1},
+	0xf2: {"firmware", 3, false, 1},
+}
+
+// BTHome button events
+var bthomeButtonEvents = map[byte]string{
+	0x00: "none",
+	0x01: "press",
+	0x02: "double_press",
+	0x03: "triple_press",
+	0x04: "long_press",
+	0x05: "long_double_press",
+	0x06: "long_triple_press",
+	0x80: "hold_press",
+}
+
+func init() {
+	registerServiceDataDecoder(bthomeUUID, "bthome", decodeBTHome)
+}
+
+// decode BTHome v2 service data, a device information byte followed by
+// objects that are each an ID and a little-endian value. Encrypted data
+// can't be decoded without the device's key
+func decodeBTHome(data []byte, sensor *Sensor) bool {
+	if len(data) < 1 || data[0]>>5 != 2 || data[0]&0x01 != 0 {
+		return false
+	}
+	var buttons []string
+	values := map[string]float64{}
+	for data = data[1:]; len(data) > 0; {
+		id := data[0]
+		data = data[1:]
+		// text and raw
+		if id == 0x53 || id == 0x54 {
+			if len(data) < 1 || len(data) < 1+int(data[0]) {
+				return false
+			}
+			data = data[1+int(data[0]):]
+			continue
+		}
+		object, ok := bthomeObjects[id]
+		if !ok || len(data) < object.size {
+			// the rest can't be found without knowing how long this is
+			break
+		}
+		raw := uint64(0)
+		for i := object.size - 1; i >= 0; i-- {
+			raw = raw<<8 | uint64(data[i])
+		}
+		if id == 0x3a {
+			event, ok := bthomeButtonEvents[data[0]]
+			if !ok {
+				event = "unknown"
+			}
+			buttons = append(buttons, event)
+		}
+		data = data[object.size:]
+		value := float64(raw)
+		if object.signed && raw&(1<<(8*object.size-1)) != 0 {
+			value -= float64(uint64(1) << (8 * object.size))
+		}
+		value = math.Round(value*object.factor*1000) / 1000
+		switch object.name {
+		case "packet", "button":
+		case "temperature":
+			sensor.Temperature = &value
+		case "humidity":
+			sensor.Humidity = &value
+		case "pressure":
+			sensor.Pressure = &value
+		case "battery":
+			level := int(value)
+			sensor.Battery = &level
+		case "voltage":
+			sensor.Voltage = &value
+		default:
+			values[object.name] = value
+		}
+	}
+	if buttons != nil {
+		sensor.Buttons = buttons
+	}
+	sensor.setValues(values)
+	return true
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestDecodeBTHome(t *testing.T) {
+	testDecoder(t, decodeBTHome, []decoderTest{
+		{"temperature and humidity", "40 02 ca09 03 bf13", map[string]float64{"temperature": 25.06, "humidity": 50.55}},
+		{"battery", "40 01 61", map[string]float64{"battery": 97}},
+		{"negative temperature", "40 02 0cfe", map[string]float64{"temperature": -5}},
+		{"pressure and voltage", "40 04 138a01 0c 020c", map[string]float64{"pressure": 1008.83, "voltage": 3.074}},
+		{"other readings", "40 05 138a14 2d 01", map[string]float64{"illuminance": 13460.67, "window": 1}},
+		{"packet ID", "40 00 09 01 64", map[string]float64{"battery": 100}},
+		{"text skipped", "40 53 02 6869 01 50", map[string]float64{"battery": 80}},
+		{"unknown object stops", "40 01 50 ee 01 02", map[string]float64{"battery": 80}},
+		{"truncated object stops", "40 01 50 02 ca", map[string]float64{"battery": 80}},
+		{"truncated text", "40 53 05 6869", nil},
+		{"encrypted", "41 02 ca09", nil},
+		{"version 1", "20 02 ca09", nil},
+		{"empty", "", nil},
+	})
+}
+
+func TestDecodeBTHomeButtons(t *testing.T) {
+	sensor := &Sensor{}
+	if !decodeBTHome([]byte{0x40, 0x3a, 0x01, 0x3a, 0x04, 0x3a, 0x7f}, sensor) {
+		t.Fatal("not decoded")
+	}
+	want := []string{"press", "long_press", "unknown"}
+	if len(sensor.Buttons) != len(want) {
+		t.Fatalf("got buttons %v, want %v", sensor.Buttons, want)
+	}
+	for i := range want {
+		if sensor.Buttons[i] != want[i] {
+			t.Errorf("got buttons %v, want %v", sensor.Buttons, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// btsnoop data link type for HCI packets prefixed with their UART (H4) type
+const btsnoopH4 = 1002
+
+// btsnoop timestamps are microseconds since midnight, January 1st, 0 AD
+const btsnoopEpoch = 0x00dcddb30f2f8000
+
+// Capture is a recording of the raw advertising reports received, in the
+// btsnoop format Wireshark reads
+type Capture struct {
+	Capturing bool      `json:"capturing"`
+	Started   time.Time `json:"started"`
+	Stopped   time.Time `json:"stopped"`
+	Packets   int       `json:"packets"`
+	Bytes     int64     `json:"bytes"`
+
+	file *os.File
+}
+
+var capture Capture
+var captureMutex sync.Mutex
+
+// start capturing to a new file, replacing the previous capture
+func startCapture() (Capture, error) {
+	captureMutex.Lock()
+	defer captureMutex.Unlock()
+	if capture.Capturing {
+		return capture, errors.New("already capturing")
+	}
+	file, err := os.CreateTemp("", "blueblue-*.btsnoop")
+	if err != nil {
+		return capture, err
+	}
+	header := make([]byte, 16)
+	copy(header, "btsnoop\x00")
+	binary.BigEndian.PutUint32(header[8:], 1)
+	binary.BigEndian.PutUint32(header[12:], btsnoopH4)
+	_, err = file.Write(header)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return capture, err
+	}
+	if capture.file != nil {
+		capture.file.Close()
+		os.Remove(capture.file.Name())
+	}
+	capture = Capture{Capturing: true, Started: time.Now(), Bytes: int64(len(header)), file: file}
+	logger.Println("Started capture to", file.Name())
+	return capture, nil
+}
+
+// stop capturing, keeping the file to be downloaded
+func stopCapture() (Capture, error) {
+	captureMutex.Lock()
+	defer captureMutex.Unlock()
+	if !capture.Capturing {
+		return capture, errors.New("not capturing")
+	}
+	capture.Capturing = false
+	capture.Stopped = time.Now()
+	logger.Println("Stopped capture with", capture.Packets, "packets")
+	return capture, nil
+}
+
+// record the advertising report that the advertisement came in, if
+// capturing. The scan response is recorded instead if there is one, because
+// the advertisement is handled again when its scan response arrives
+func captureAdvertisement(a ble.Advertisement) {
+	captureMutex.Lock()
+	defer captureMutex.Unlock()
+	if !capture.Capturing {
+		return
+	}
+	report := a.ScanResponseRaw()
+	if report == nil {
+		report = a.LEAdvertisingReportRaw()
+	}
+	// an HCI LE Meta event, whose parameters are the report
+	packet := append([]byte{0x04, 0x3e, byte(len(report))}, report...)
+	record := make([]byte, 24, 24+len(packet))
+	binary.BigEndian.PutUint32(record[0:], uint32(len(packet)))
+	binary.BigEndian.PutUint32(record[4:], uint32(len(packet)))
+	binary.BigEndian.PutUint32(record[8:], 0x03) // received event
+	binary.BigEndian.PutUint64(record[16:], uint64(time.Now().UnixMicro()+btsnoopEpoch))
+	_, err := capture.file.Write(append(record, packet...))
+	if err != nil {
+		logger.Println("Cannot write capture, stopping it:", err)
+		capture.Capturing = false
+		capture.Stopped = time.Now()
+		return
+	}
+	capture.Packets++
+	capture.Bytes += int64(len(record) + len(packet))
+}
+
+// handler to show (GET), start (POST) and stop (DELETE) the capture
+func apiCapture(w http.ResponseWriter, r *http.Request) {
+	var c Capture
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		captureMutex.Lock()
+		c = capture
+		captureMutex.Unlock()
+	case http.MethodPost:
+		c, err = startCapture()
+	case http.MethodDelete:
+		c, err = stopCapture()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// handler to download the capture so far, or the last one
+func apiCaptureDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	captureMutex.Lock()
+	if capture.file == nil {
+		captureMutex.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	file, err := os.Open(capture.file.Name())
+	size, started := capture.Bytes, capture.Started
+	captureMutex.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="blueblue-`+started.Format("20060102-150405")+`.btsnoop"`)
+	// only what was written when the download started, so it ends on a record
+	_, err = io.CopyN(w, file, size)
+	if err != nil {
+		logger.Println("Cannot send capture:", err)
+	}
+}
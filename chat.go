@@ -0,0 +1,21 @@
+package main
+
+// send a message for each of the events wanted to a Slack or Discord
+// channel, through its incoming webhook, which take the text in different
+// fields
+func startChat(name, webhook, events, field string) error {
+	messages, err := parseMessages(*messagesPath)
+	if err != nil {
+		return err
+	}
+	go notify(name, eventTypes(events), func(e Event) error {
+		text, err := eventMessage(messages, e)
+		if err != nil {
+			logger.Println("Cannot make", name, "message:", err)
+			return nil
+		}
+		return postJSON(webhook, map[string]string{field: text})
+	})
+	logger.Println("Sending", events, "events to", name)
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// the subcommands, serve is the default so blueblue still starts the server
+// when run without one
+var commands = []struct {
+	name, args, usage string
+}{
+	{"serve", "", "scan and serve the web pages and APIs (the default)"},
+	{"scan", "[seconds]", "scan once for a while, 10 seconds by default, and print the devices seen"},
+	{"export", "", "write the detections recorded in the -db database to stdout as lines of JSON"},
+	{"version", "", "print the version"},
+}
+
+// the subcommand being run
+var command = "serve"
+
+// parse the subcommand, if there is one, and the flags after it
+func parseCommand(args []string) {
+	flag.Usage = usage
+	if len(args) > 0 {
+		for _, c := range commands {
+			if args[0] == c.name {
+				command = c.name
+				args = args[1:]
+				break
+			}
+		}
+	}
+	flag.CommandLine.Parse(args)
+}
+
+// print how to use blueblue
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags] [args]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-8s %-10s %s\n", c.name, c.args, c.usage)
+	}
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// print an error for the command line and exit
+func fail(message string, err error) {
+	fmt.Fprintln(os.Stderr, message, err)
+	os.Exit(1)
+}
+
+// print the version of the running build
+func printVersion(w io.Writer) {
+	v := currentVersion()
+	fmt.Fprintln(w, "blueblue", v.Version)
+	if v.Commit != "" {
+		fmt.Fprintln(w, "commit", v.Commit)
+	}
+	if v.BuildDate != "" {
+		fmt.Fprintln(w, "built", v.BuildDate)
+	}
+	fmt.Fprintln(w, v.GoVersion)
+}
+
+// how long to scan once for, from the first argument
+func scanFor() (time.Duration, error) {
+	if flag.NArg() == 0 {
+		return 10 * time.Second, nil
+	}
+	seconds, err := strconv.Atoi(flag.Arg(0))
+	if err != nil || seconds < 1 {
+		return 0, fmt.Errorf("invalid number of seconds %q", flag.Arg(0))
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// scan for a while and print the devices seen, strongest first, as a table
+// or as lines of JSON with -ndjson
+func scanOnce(d time.Duration, w io.Writer) error {
+	startScanning()
+	time.Sleep(d)
+	stopScanning()
+	scanning.Wait()
+	adaptersMutex.Lock()
+	closeAdapters(adapters)
+	adaptersMutex.Unlock()
+	list := deviceList()
+	if *ndjson {
+		encoder := json.NewEncoder(w)
+		for _, device := range list {
+			err := encoder.Encode(device)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tRSSI\tNAME\tVENDOR")
+	for _, device := range list {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", device.Address, device.RSSI, device.Name, device.Vendor)
+	}
+	return tw.Flush()
+}
+
+// write every detection in the database to w as a line of JSON, oldest first
+func exportDetections(path string, w io.Writer) error {
+	if path == "" {
+		return fmt.Errorf("no database, use -db")
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT address, name, rssi, detected, advertisement, scan_response
+		FROM detections ORDER BY detected`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	encoder := json.NewEncoder(w)
+	for rows.Next() {
+		device := Device{}
+		var detected string
+		err = rows.Scan(&device.Address, &device.Name, &device.RSSI, &detected,
+			&device.Advertisement, &device.ScanResponse)
+		if err != nil {
+			return err
+		}
+		device.Detected, err = parseTimestamp(detected)
+		if err != nil {
+			return err
+		}
+		err = encoder.Encode(device)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// compressWriter compresses the response body, starting the compressor only
+// when there's a body so empty responses stay empty
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	writer   io.WriteCloser
+	// the status sent, 0 until the headers are
+	status int
+}
+
+// WriteHeader sets the content encoding before sending the headers
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+	if bodyAllowed(status) {
+		cw.Header().Set("Content-Encoding", cw.encoding)
+		cw.Header().Del("Content-Length")
+	}
+	cw.Header().Add("Vary", "Accept-Encoding")
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// whether a response with the status has a body to compress
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// send the headers if they haven't been, and start the compressor
+func (cw *compressWriter) start() {
+	cw.WriteHeader(http.StatusOK)
+	if cw.writer != nil || !bodyAllowed(cw.status) {
+		return
+	}
+	if cw.encoding == "gzip" {
+		cw.writer = gzip.NewWriter(cw.ResponseWriter)
+	} else {
+		// HTTP deflate is zlib, not raw deflate
+		cw.writer = zlib.NewWriter(cw.ResponseWriter)
+	}
+}
+
+// Write compresses the data
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	cw.start()
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(data)
+	}
+	return cw.writer.Write(data)
+}
+
+// Flush sends what has been compressed so far. Streams flush before they
+// write anything, so this starts the compressor too
+func (cw *compressWriter) Flush() {
+	cw.start()
+	if f, ok := cw.writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the compressed body
+func (cw *compressWriter) Close() error {
+	if cw.writer == nil {
+		return nil
+	}
+	return cw.writer.Close()
+}
+
+// compress responses with gzip or deflate if the client accepts them, except
+// for streams and the metrics, which compresses itself
+func compress(handler http.Handler) http.Handler {
+	if !*compression {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept-Encoding")
+		if r.Header.Get("Upgrade") != "" || strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+			strings.HasSuffix(r.URL.Path, "/metrics") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		encoding := ""
+		switch {
+		case strings.Contains(accept, "gzip"):
+			encoding = "gzip"
+		case strings.Contains(accept, "deflate"):
+			encoding = "deflate"
+		default:
+			handler.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		handler.ServeHTTP(cw, r)
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config is the configuration that can be changed at runtime
+type Config struct {
+	Scan ScanSettings `json:"scan"`
+	// how the RSSI of each device is smoothed
+	Smoothing Smoothing `json:"smoothing"`
+	// how long a device stays visible after it was last seen, in seconds
+	Expiry float64 `json:"expiry"`
+	// path loss exponent of the environment for estimating distances, 2 in
+	// free space and up to 4 indoors, 0 for free space
+	PathLoss float64 `json:"pathloss"`
+	// the power devices are received with at 1m, by address, for devices
+	// that don't advertise it
+	MeasuredPower map[string]int `json:"measuredpower,omitempty"`
+	// ignore advertisements weaker than this, 0 to report all
+	MinRSSI int `json:"minrssi"`
+	// MQTT topic prefix and QoS of published devices
+	MQTTTopic string `json:"mqtttopic"`
+	MQTTQoS   int    `json:"mqttqos"`
+	// battery level in percent at or below which a battery is low
+	BatteryLow int `json:"batterylow"`
+	// how long an unknown tracker follows the scanner before an alert is
+	// raised, in seconds, 0 to never raise one
+	TrackerAlert float64 `json:"trackeralert"`
+	// addresses or groups of private addresses of trackers that never raise
+	// an alert
+	KnownTrackers []string `json:"knowntrackers,omitempty"`
+	// the known devices whose presence is tracked
+	Presence []PresenceDevice `json:"presence,omitempty"`
+	// the rules advertisements are matched against
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+var config Config
+var configMutex sync.Mutex
+
+// check the configuration is usable
+func (c Config) validate() error {
+	err := c.Scan.validate()
+	if err != nil {
+		return err
+	}
+	err = c.Smoothing.validate()
+	if err != nil {
+		return err
+	}
+	if c.Expiry <= 0 {
+		return fmt.Errorf("invalid expiry %gs, must be more than 0s", c.Expiry)
+	}
+	if c.PathLoss < 0 {
+		return fmt.Errorf("invalid path loss exponent %g, must be 0 or more", c.PathLoss)
+	}
+	if c.MinRSSI > 0 {
+		return fmt.Errorf("invalid minimum RSSI %d, must be 0 or less", c.MinRSSI)
+	}
+	if c.MQTTQoS < 0 || c.MQTTQoS > 2 {
+		return fmt.Errorf("invalid MQTT QoS %d, must be 0, 1 or 2", c.MQTTQoS)
+	}
+	if c.BatteryLow < 0 || c.BatteryLow > 100 {
+		return fmt.Errorf("invalid battery low level %d%%, must be between 0%% and 100%%", c.BatteryLow)
+	}
+	if c.TrackerAlert < 0 {
+		return fmt.Errorf("invalid tracker alert time %gs, must be 0s or more", c.TrackerAlert)
+	}
+	names := map[string]bool{}
+	for _, p := range c.Presence {
+		err = p.validate()
+		if err != nil {
+			return err
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate presence device %s", p.Name)
+		}
+		names[p.Name] = true
+	}
+	rules := map[string]bool{}
+	for _, r := range c.Rules {
+		err = r.validate()
+		if err != nil {
+			return err
+		}
+		if rules[r.Name] {
+			return fmt.Errorf("duplicate rule %s", r.Name)
+		}
+		rules[r.Name] = true
+	}
+	return nil
+}
+
+// the current configuration
+func currentConfig() Config {
+	configMutex.Lock()
+	c := config
+	configMutex.Unlock()
+	c.Scan = currentScanSettings()
+	return c
+}
+
+// change the configuration, the scan settings take effect from the next scan
+// and everything else immediately
+func setConfig(c Config) error {
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+	err = setScanSettings(c.Scan)
+	if err != nil {
+		return err
+	}
+	configMutex.Lock()
+	config = c
+	configMutex.Unlock()
+	logger.Println("Changed configuration to", c)
+	return nil
+}
+
+// how long a device stays visible after it was last seen
+func expiry() time.Duration {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	return time.Duration(config.Expiry * float64(time.Second))
+}
+
+// the visibility window asked for with the expiry query parameter, in
+// seconds, or the configured one. Devices are forgotten once they expire, so
+// a longer window than the configured one shows no more devices
+func requestExpiry(r *http.Request) (time.Duration, error) {
+	e := r.URL.Query().Get("expiry")
+	if e == "" {
+		return expiry(), nil
+	}
+	seconds, err := strconv.ParseFloat(e, 64)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("invalid expiry %q, must be a number of seconds more than 0", e)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+// labels of the types of Apple Continuity messages, the type-length-value
+// items in Apple's manufacturer data
+var continuityTypes = map[byte]string{
+	0x02: "iBeacon",
+	0x03: "AirPrint",
+	0x05: "AirDrop",
+	0x06: "HomeKit",
+	0x07: "Proximity Pairing",
+	0x08: "Hey Siri",
+	0x09: "AirPlay Target",
+	0x0a: "AirPlay Source",
+	0x0b: "Magic Switch",
+	0x0c: "Handoff",
+	0x0d: "Tethering Target",
+	0x0e: "Tethering Source",
+	0x0f: "Nearby Action",
+	0x10: "Nearby Info",
+	0x12: "Find My",
+}
+
+// the labels of the Continuity messages in the manufacturer data, or nil if
+// it's not Apple's. Types without a label are given in hex
+func continuityMessages(data []byte) []string {
+	if len(data) < 2 || data[0] != 0x4c || data[1] != 0x00 {
+		return nil
+	}
+	var messages []string
+	for data = data[2:]; len(data) >= 2 && 2+int(data[1]) <= len(data); data = data[2+int(data[1]):] {
+		label, ok := continuityTypes[data[0]]
+		if !ok {
+			label = fmt.Sprintf("0x%02x", data[0])
+		}
+		messages = append(messages, label)
+	}
+	return messages
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// a device whose private address rotates stops advertising with the old
+// address and starts with the new one within this long
+const rotationGap = 30 * time.Second
+
+// the most addresses remembered for each group
+const groupAddresses = 16
+
+// Group is the private addresses that are likely to be the same device
+// rotating its address, because they advertised the same payload one after
+// the other
+type Group struct {
+	ID          string    `json:"id"`
+	Fingerprint string    `json:"fingerprint"`
+	Addresses   []string  `json:"addresses"` // oldest first
+	Current     string    `json:"current"`
+	Detected    time.Time `json:"detected"`
+	// estimated advertising interval of the current address, in milliseconds
+	interval float64
+}
+
+// the groups of private addresses, by ID, protected by the device mutex
+var groups = make(map[string]*Group)
+
+// the fingerprint of the parts of a device's advertisement that stay the same
+// when its address rotates, or "" if there's too little to go on. Only the
+// advertisement is used, since the scan response isn't there when a new
+// address is first seen. Manufacturer data changes all the time, so only
+// the company and the kinds of data are used
+func fingerprint(device Device) string {
+	if device.AD == nil {
+		return ""
+	}
+	parts := []string{}
+	for _, s := range device.AD.Structures {
+		if s.Source != SourceAdv {
+			continue
+		}
+		value, _ := hex.DecodeString(s.Data)
+		switch s.Type {
+		case adFlags, adIncomplete16, adComplete16, adIncomplete32, adComplete32, adIncomplete128, adComplete128,
+			adShortName, adCompleteName, adTxPower, adAppearance:
+			parts = append(parts, fmt.Sprintf("%02x:%x", s.Type, value))
+		case adServiceData16, adServiceData32, adServiceData128:
+			size := map[uint8]int{adServiceData16: 2, adServiceData32: 4, adServiceData128: 16}[s.Type]
+			if len(value) >= size {
+				parts = append(parts, fmt.Sprintf("%02x:%x", s.Type, value[:size]))
+			}
+		case adManufacturerData:
+			if len(value) >= 2 {
+				parts = append(parts, fmt.Sprintf("%02x:%x:%s", s.Type, value[:2], dataKinds(value)))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("%02x", s.Type))
+		}
+	}
+	// flags alone say nothing about the device
+	if len(parts) == 0 || (len(parts) == 1 && strings.HasPrefix(parts[0], "01:")) {
+		return ""
+	}
+	return strings.Join(parts, ",")
+}
+
+// the kinds of data in manufacturer data, the types of the type-length-value
+// items for Apple, and the length for everyone else
+func dataKinds(value []byte) string {
+	if value[0] != 0x4c || value[1] != 0x00 {
+		return fmt.Sprint(len(value))
+	}
+	kinds := []string{}
+	for data := value[2:]; len(data) >= 2; data = data[2+int(data[1]):] {
+		kinds = append(kinds, fmt.Sprintf("%02x", data[0]))
+		if 2+int(data[1]) > len(data) {
+			break
+		}
+	}
+	return strings.Join(kinds, ".")
+}
+
+// put the device in the group of the private address it has likely rotated
+// from, or in a new group, if its address is private. Must be called with
+// the device mutex held
+func correlate(device *Device, found bool, previous Device) {
+	if device.AddressType != AddressResolvable && device.AddressType != AddressNonResolvable ||
+		device.PrivateAddress != "" {
+		return
+	}
+	// only new addresses can be rotated to
+	if found {
+		device.Group = previous.Group
+		if group, ok := groups[device.Group]; ok {
+			group.Detected = device.Detected
+			group.interval = device.Interval
+		}
+		return
+	}
+	f := fingerprint(*device)
+	if f == "" {
+		return
+	}
+	// the group that stopped advertising most recently, shortly before
+	var match *Group
+	for _, group := range groups {
+		gap := device.Detected.Sub(group.Detected)
+		if group.Fingerprint != f || group.Current == device.Address || gap <= 0 || gap > rotationGap {
+			continue
+		}
+		// still advertising with its address, so another device
+		if current, ok := devices[group.Current]; ok && current.Detected.After(device.Detected.Add(-time.Second)) {
+			continue
+		}
+		if group.interval != 0 && device.Interval != 0 && math.Abs(group.interval-device.Interval) > group.interval/4 {
+			continue
+		}
+		if match == nil || group.Detected.After(match.Detected) {
+			match = group
+		}
+	}
+	if match == nil {
+		match = &Group{ID: device.Address, Fingerprint: f}
+		groups[match.ID] = match
+	}
+	match.Addresses = append(match.Addresses, device.Address)
+	if len(match.Addresses) > groupAddresses {
+		match.Addresses = match.Addresses[1:]
+	}
+	match.Current = device.Address
+	match.Detected = device.Detected
+	match.interval = device.Interval
+	device.Group = match.ID
+}
+
+// forget the groups that haven't been seen since the cutoff and couldn't
+// rotate again, must be called with the device mutex held
+func expireGroups(cutoff time.Time) {
+	for id, group := range groups {
+		if group.Detected.Before(cutoff.Add(-rotationGap)) {
+			delete(groups, id)
+		}
+	}
+}
+
+// the number of distinct devices in the list, counting each group of
+// private addresses once
+func distinctDevices(list []Device) int {
+	seen := map[string]bool{}
+	for _, device := range list {
+		if device.Group != "" {
+			seen["group "+device.Group] = true
+		} else {
+			seen[device.Address] = true
+		}
+	}
+	return len(seen)
+}
+
+// handler to list the groups of private addresses that are visible, with
+// the most addresses first
+func apiGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	cutoff := time.Now().Add(-expiry())
+	mutex.RLock()
+	list := []Group{}
+	for _, group := range groups {
+		if group.Detected.After(cutoff) {
+			g := *group
+			g.Addresses = append([]string{}, group.Addresses...)
+			list = append(list, g)
+		}
+	}
+	mutex.RUnlock()
+	sort.SliceStable(list, func(i, j int) bool {
+		return len(list[i].Addresses) > len(list[j].Addresses)
+	})
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/binary"
+
+	"github.com/sausheong/ble"
+)
+
+// Sensor is the readings decoded from the vendor-specific data a device
+// advertises
+type Sensor struct {
+	// the decoder that last decoded the data
+	Format string `json:"format"`
+	// in degrees Celsius, percent relative humidity and hPa
+	Temperature *float64 `json:"temperature,omitempty"`
+	Humidity    *float64 `json:"humidity,omitempty"`
+	Pressure    *float64 `json:"pressure,omitempty"`
+	// battery level in percent and voltage in volts
+	Battery *int     `json:"battery,omitempty"`
+	Voltage *float64 `json:"voltage,omitempty"`
+	// the last event of each button
+	Buttons []string `json:"buttons,omitempty"`
+	// any other readings, by name
+	Values map[string]float64 `json:"values,omitempty"`
+}
+
+// add the readings to the other readings, without changing the map of the
+// previous readings, which devices that have been copied still share
+func (s *Sensor) setValues(values map[string]float64) {
+	if len(values) == 0 {
+		return
+	}
+	merged := map[string]float64{}
+	for name, value := range s.Values {
+		merged[name] = value
+	}
+	for name, value := range values {
+		merged[name] = value
+	}
+	s.Values = merged
+}
+
+// all the readings by name, as published over MQTT
+func (s *Sensor) readings() map[string]float64 {
+	readings := map[string]float64{}
+	for name, value := range s.Values {
+		readings[name] = value
+	}
+	for name, value := range map[string]*float64{
+		"temperature": s.Temperature,
+		"humidity":    s.Humidity,
+		"pressure":    s.Pressure,
+		"voltage":     s.Voltage,
+	} {
+		if value != nil {
+			readings[name] = *value
+		}
+	}
+	if s.Battery != nil {
+		readings["battery"] = float64(*s.Battery)
+	}
+	return readings
+}
+
+// a decoder turns the vendor-specific data a device advertises into sensor
+// readings, updating the sensor with them. Returns false if the data isn't
+// in a format it knows
+type decoder func(data []byte, sensor *Sensor) bool
+
+type namedDecoder struct {
+	name   string
+	decode decoder
+	// whether the advertisement can be from a device of the format, nil if
+	// the data alone is enough to tell
+	accepts func(a ble.Advertisement) bool
+}
+
+// the decoders of service data by service UUID, and of manufacturer data by
+// company ID, registered at startup in the init of each decoder's file
+var (
+	serviceDataDecoders      = map[string][]namedDecoder{}
+	manufacturerDataDecoders = map[uint16][]namedDecoder{}
+)
+
+// register a decoder of the service data for the service UUID
+func registerServiceDataDecoder(uuid ble.UUID, name string, decode decoder) {
+	key := uuid.String()
+	serviceDataDecoders[key] = append(serviceDataDecoders[key], namedDecoder{name: name, decode: decode})
+}
+
+// register a decoder of the manufacturer data for the company ID, which gets
+// the data after the company ID
+func registerManufacturerDataDecoder(company uint16, name string, decode decoder) {
+	registerGuardedManufacturerDataDecoder(company, name, nil, decode)
+}
+
+// register a decoder of the manufacturer data for the company ID that only
+// decodes advertisements the guard accepts, for vendors that use a company ID
+// that isn't theirs
+func registerGuardedManufacturerDataDecoder(company uint16, name string, accepts func(a ble.Advertisement) bool, decode decoder) {
+	manufacturerDataDecoders[company] = append(manufacturerDataDecoders[company], namedDecoder{name, decode, accepts})
+}
+
+// decode the service data and manufacturer data of the advertisement with
+// the registered decoders, on top of the previous readings since some
+// devices send their readings in turns. Returns the previous readings if
+// nothing could be decoded
+func decodeSensor(a ble.Advertisement, previous *Sensor) *Sensor {
+	sensor := &Sensor{}
+	if previous != nil {
+		*sensor = *previous
+	}
+	decoded := false
+	for _, sd := range a.ServiceData() {
+		for _, d := range serviceDataDecoders[sd.UUID.String()] {
+			if d.decode(sd.Data, sensor) {
+				sensor.Format = d.name
+				decoded = true
+				break
+			}
+		}
+	}
+	if data := a.ManufacturerData(); len(data) >= 2 {
+		for _, d := range manufacturerDataDecoders[binary.LittleEndian.Uint16(data)] {
+			if d.accepts != nil && !d.accepts(a) {
+				continue
+			}
+			if d.decode(data[2:], sensor) {
+				sensor.Format = d.name
+				decoded = true
+				break
+			}
+		}
+	}
+	if !decoded {
+		return previous
+	}
+	return sensor
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/sausheong/ble"
+)
+
+// a decoder test, the data in hex and the readings it decodes to, or nil if
+// it can't be decoded
+type decoderTest struct {
+	name string
+	data string
+	want map[string]float64
+}
+
+// run the decoder on the data of each test and check the readings
+func testDecoder(t *testing.T, decode decoder, tests []decoderTest) {
+	t.Helper()
+	for _, test := range tests {
+		data, err := hex.DecodeString(strings.ReplaceAll(test.data, " ", ""))
+		if err != nil {
+			t.Fatalf("%s: invalid test data: %v", test.name, err)
+		}
+		sensor := &Sensor{}
+		ok := decode(data, sensor)
+		if test.want == nil {
+			if ok {
+				t.Errorf("%s: decoded %v, want it not to be", test.name, sensor.readings())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: not decoded", test.name)
+			continue
+		}
+		checkReadings(t, test.name, sensor.readings(), test.want)
+	}
+}
+
+// check the readings are the ones wanted, to within rounding
+func checkReadings(t *testing.T, name string, got, want map[string]float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got %v, want %v", name, got, want)
+		return
+	}
+	for reading, value := range want {
+		if v, ok := got[reading]; !ok || math.Abs(v-value) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+			return
+		}
+	}
+}
+
+// an advertisement with the AD structures
+func testAdvertisement(structures ...[]byte) *replayedAdvertisement {
+	data := []byte{}
+	for _, s := range structures {
+		data = append(data, s...)
+	}
+	address := [6]byte{0xa4, 0xc1, 0x38, 0x11, 0x22, 0x33}
+	return &replayedAdvertisement{e: advertisingReport(advInd, addressPublic, address, data, -60)}
+}
+
+func TestDecodeSensorRegistry(t *testing.T) {
+	uuid := ble.UUID16(0xfff0)
+	company := uint16(0xfff0)
+	// a decoder that only decodes data of the given length
+	length := func(n int) decoder {
+		return func(data []byte, sensor *Sensor) bool { return len(data) == n }
+	}
+	registerServiceDataDecoder(uuid, "short", length(1))
+	registerServiceDataDecoder(uuid, "long", length(2))
+	registerManufacturerDataDecoder(company, "vendor", length(1))
+	defer func() {
+		delete(serviceDataDecoders, uuid.String())
+		delete(manufacturerDataDecoders, company)
+	}()
+
+	tests := []struct {
+		name      string
+		structure []byte
+		want      string
+	}{
+		{"first decoder", adStructure(adServiceData16, 0xf0, 0xff, 0x01), "short"},
+		{"second decoder", adStructure(adServiceData16, 0xf0, 0xff, 0x01, 0x02), "long"},
+		{"manufacturer data", adStructure(adManufacturerData, 0xf0, 0xff, 0x01), "vendor"},
+	}
+	for _, test := range tests {
+		sensor := decodeSensor(testAdvertisement(test.structure), nil)
+		if sensor == nil || sensor.Format != test.want {
+			t.Errorf("%s: got %+v, want format %s", test.name, sensor, test.want)
+		}
+	}
+
+	previous := &Sensor{Format: "short"}
+	if got := decodeSensor(testAdvertisement(adStructure(adServiceData16, 0xf0, 0xff, 0x01, 0x02, 0x03)), previous); got != previous {
+		t.Errorf("got %+v, want the previous readings when no decoder knows the data", got)
+	}
+	if got := decodeSensor(testAdvertisement(adStructure(adCompleteName, 'A', 'T', 'C')), previous); got != previous {
+		t.Errorf("got %+v, want the previous readings when there's nothing to decode", got)
+	}
+	if got := decodeSensor(testAdvertisement(adStructure(adManufacturerData, 0xf0)), nil); got != nil {
+		t.Errorf("got %+v from a truncated company ID, want nothing", got)
+	}
+}
+
+func TestSensorSetValuesCopies(t *testing.T) {
+	previous := &Sensor{Values: map[string]float64{"moisture": 30}}
+	sensor := *previous
+	sensor.setValues(map[string]float64{"conductivity": 200})
+	if len(previous.Values) != 1 {
+		t.Errorf("previous values changed to %v", previous.Values)
+	}
+	checkReadings(t, "merged", sensor.readings(), map[string]float64{"moisture": 30, "conductivity": 200})
+}
+
+func TestDecodeSensor(t *testing.T) {
+	atc := []byte{0x1a, 0x18, 0xa4, 0xc1, 0x38, 0x11, 0x22, 0x33, 0x00, 0xe1, 0x32, 0x5a, 0x0b, 0x8a, 0x01}
+	pvvx := []byte{0x1a, 0x18, 0x33, 0x22, 0x11, 0x38, 0xc1, 0xa4, 0xca, 0x08, 0x88, 0x13, 0x8a, 0x0b, 0x5a, 0x01, 0x00}
+	ruuvi := []byte{0x99, 0x04, 0x03, 0x29, 0x1a, 0x1e, 0xce, 0x1e, 0xfc, 0x18, 0xf9, 0x42, 0x02, 0xca, 0x0b, 0x53}
+
+	sensor := decodeSensor(testAdvertisement(adStructure(adServiceData16, atc...)), nil)
+	if sensor == nil || sensor.Format != "atc" {
+		t.Fatalf("got %+v, want ATC readings", sensor)
+	}
+	checkReadings(t, "atc", sensor.readings(), map[string]float64{"temperature": 22.5, "humidity": 50, "battery": 90, "voltage": 2.954})
+
+	sensor = decodeSensor(testAdvertisement(adStructure(adServiceData16, pvvx...)), nil)
+	if sensor == nil || sensor.Format != "pvvx" {
+		t.Fatalf("got %+v, want pvvx readings", sensor)
+	}
+
+	sensor = decodeSensor(testAdvertisement(adStructure(adManufacturerData, ruuvi...)), nil)
+	if sensor == nil || sensor.Format != "ruuvi3" {
+		t.Fatalf("got %+v, want Ruuvi readings", sensor)
+	}
+}
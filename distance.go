@@ -0,0 +1,41 @@
+package main
+
+import "math"
+
+// the path loss from 0m to 1m, to get the power at 1m from the TX power
+// advertised by Eddystone and the TX Power Level AD structure
+const pathLoss1m = 41
+
+// the power the device is received with at 1m, configured for the device,
+// or as advertised
+func measuredPower(device Device, c Config) (int, bool) {
+	if power, ok := c.MeasuredPower[device.Address]; ok {
+		return power, true
+	}
+	switch {
+	case device.IBeacon != nil:
+		return device.IBeacon.TxPower, true
+	case device.AltBeacon != nil:
+		return device.AltBeacon.ReferenceRSSI, true
+	case device.Eddystone != nil:
+		return device.Eddystone.TxPower - pathLoss1m, true
+	case device.TxPower != nil:
+		return *device.TxPower - pathLoss1m, true
+	}
+	return 0, false
+}
+
+// estimate how far away the device is in meters from its smoothed RSSI,
+// with the log-distance path loss model, or 0 if its power at 1m isn't known
+func estimateDistance(device Device, c Config) float64 {
+	power, ok := measuredPower(device, c)
+	if !ok || device.SmoothedRSSI == 0 {
+		return 0
+	}
+	n := c.PathLoss
+	if n == 0 {
+		n = 2
+	}
+	distance := math.Pow(10, (float64(power)-device.SmoothedRSSI)/(10*n))
+	return math.Round(distance*100) / 100
+}
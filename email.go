@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// the most devices listed in each part of the digest
+const digestTop = 10
+
+// Digest is what was seen since the last daily digest
+type Digest struct {
+	start time.Time
+	// advertisements received from each address
+	advertisements map[string]int
+	names          map[string]string
+	// the addresses first seen during the day
+	new map[string]bool
+}
+
+// start sending emails for the events wanted and, if there's a time for it,
+// the daily digest
+func startEmail() error {
+	messages, err := parseMessages(*messagesPath)
+	if err != nil {
+		return err
+	}
+	digestAt := -1
+	if *emailDigest != "" {
+		var h, m int
+		_, err = fmt.Sscanf(*emailDigest, "%d:%d", &h, &m)
+		if err != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+			return fmt.Errorf("invalid digest time %q, must be hh:mm", *emailDigest)
+		}
+		digestAt = h*60 + m
+	}
+	if *emailEvents != "" {
+		go notify("email", eventTypes(*emailEvents), func(e Event) error {
+			text, err := eventMessage(messages, e)
+			if err != nil {
+				logger.Println("Cannot make email message:", err)
+				return nil
+			}
+			return sendEmail(text, text+"\n")
+		})
+	}
+	if digestAt >= 0 {
+		go sendDigests(digestAt)
+	}
+	logger.Println("Sending", *emailEvents, "events by email to", *emailTo)
+	return nil
+}
+
+// send an email to every recipient through the SMTP server, authenticating
+// if there's a user
+func sendEmail(subject, body string) error {
+	host, _, err := net.SplitHostPort(*smtpServer)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if *smtpUser != "" {
+		auth = smtp.PlainAuth("", *smtpUser, *smtpPassword, host)
+	}
+	to := strings.Split(*emailTo, ",")
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", *emailFrom)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerText(subject)))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(*smtpServer, auth, *emailFrom, to, msg.Bytes())
+}
+
+// the text with its control characters, like line breaks, replaced with
+// spaces so it can't add headers, since it may have device names in it
+func headerText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+}
+
+// collect what's seen during each day and send the digest of it every day at
+// the time, in minutes since midnight, until shutdown
+func sendDigests(at int) {
+	ch := broker.Subscribe()
+	defer broker.Unsubscribe(ch)
+	digest := newDigest(time.Now())
+	timer := time.NewTimer(time.Until(nextDigest(time.Now(), at)))
+	for {
+		select {
+		case e := <-ch:
+			digest.add(e)
+		case <-timer.C:
+			sent := digest
+			digest = newDigest(time.Now())
+			go retry("digest", func() error {
+				return sendEmail("blueblue digest for "+hostname, sent.String())
+			})
+			timer.Reset(time.Until(nextDigest(time.Now(), at)))
+		case <-shuttingDown:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// the next time it's the time of day, in minutes since midnight, after now
+func nextDigest(now time.Time, at int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), at/60, at%60, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func newDigest(start time.Time) *Digest {
+	return &Digest{
+		start:          start,
+		advertisements: map[string]int{},
+		names:          map[string]string{},
+		new:            map[string]bool{},
+	}
+}
+
+// count the advertisement in the event, if it is one
+func (d *Digest) add(e Event) {
+	if e.Type != EventNew && e.Type != EventUpdate {
+		return
+	}
+	d.advertisements[e.Device.Address]++
+	if e.Device.Name != "" {
+		d.names[e.Device.Address] = e.Device.Name
+	}
+	if !e.Device.FirstSeen.Before(d.start) {
+		d.new[e.Device.Address] = true
+	}
+}
+
+// the text of the digest, how many devices were seen and which were new, and
+// the devices that sent the most advertisements
+func (d *Digest) String() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Since %s, %s saw %d devices, %d of them new.\n",
+		d.start.Format("2006-01-02 15:04"), hostname, len(d.advertisements), len(d.new))
+	addresses := []string{}
+	for address := range d.advertisements {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return d.advertisements[addresses[i]] > d.advertisements[addresses[j]]
+	})
+	fmt.Fprintf(buf, "\nTop talkers:\n")
+	for i, address := range addresses {
+		if i == digestTop {
+			break
+		}
+		fmt.Fprintf(buf, "  %s: %d advertisements\n", d.label(address), d.advertisements[address])
+	}
+	fmt.Fprintf(buf, "\nNew devices:\n")
+	listed := 0
+	for _, address := range addresses {
+		if !d.new[address] {
+			continue
+		}
+		if listed == digestTop {
+			fmt.Fprintf(buf, "  and %d more\n", len(d.new)-listed)
+			break
+		}
+		fmt.Fprintf(buf, "  %s\n", d.label(address))
+		listed++
+	}
+	return buf.String()
+}
+
+// the address and name of the device, if it has one
+func (d *Digest) label(address string) string {
+	if name := d.names[address]; name != "" {
+		return address + " " + name
+	}
+	return address
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"mime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailSubjectCannotAddHeaders(t *testing.T) {
+	for _, name := range []string{"evil\r\nBcc: x@example.com", "evil\rBcc: x@example.com", "café\x00"} {
+		subject := mime.QEncoding.Encode("utf-8", headerText("New device "+name))
+		if strings.ContainsAny(subject, "\r\n\x00") {
+			t.Errorf("subject for %q is %q, has control characters", name, subject)
+		}
+		decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+		if err != nil || !strings.HasPrefix(decoded, "New device ") {
+			t.Errorf("subject for %q decodes to %q, %v", name, decoded, err)
+		}
+	}
+}
+
+func TestNextDigest(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		at   int
+		want time.Time
+	}{
+		{10 * 60, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{9*60 + 30, time.Date(2024, 5, 2, 9, 30, 0, 0, time.UTC)},
+		{8 * 60, time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := nextDigest(now, test.at); !got.Equal(test.want) {
+			t.Errorf("nextDigest(%v, %d) = %v, want %v", now, test.at, got, test.want)
+		}
+	}
+}
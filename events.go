@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// event types
+const (
+	EventNew    = "new"
+	EventUpdate = "update"
+	EventExpire = "expire"
+)
+
+// Event represents a change to a device
+type Event struct {
+	Type   string `json:"type"`
+	Device Device `json:"device"`
+	// the presence of the known device that arrived or departed
+	Presence *Presence `json:"presence,omitempty"`
+	// the name of the rule the device matched
+	Rule string `json:"rule,omitempty"`
+}
+
+// Broker fans out events to all subscribers
+type Broker struct {
+	mutex       sync.Mutex
+	subscribers map[chan Event]bool
+}
+
+var broker = NewBroker()
+
+// NewBroker creates a new event broker
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[chan Event]bool),
+	}
+}
+
+// Subscribe returns a channel that receives all published events
+func (b *Broker) Subscribe() chan Event {
+	ch := make(chan Event, 64)
+	b.mutex.Lock()
+	b.subscribers[ch] = true
+	b.mutex.Unlock()
+	return ch
+}
+
+// Unsubscribe removes the channel from the subscribers
+func (b *Broker) Unsubscribe(ch chan Event) {
+	b.mutex.Lock()
+	delete(b.subscribers, ch)
+	b.mutex.Unlock()
+}
+
+// Publish sends the event to every subscriber, dropping it for
+// subscribers that are not keeping up
+func (b *Broker) Publish(e Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// forget every device that has dropped out of the visibility window, with
+// its history and packet counts, the groups of private addresses that are
+// gone for good, the trackers that stopped following and the Exposure
+// Notifications that are gone. Publish an expire event for the devices that
+// dropped out since the last check, and a depart event for the known devices
+// that have been away for their timeout
+func expireDevices() {
+	last := time.Now().Add(-expiry())
+	for range time.Tick(time.Second) {
+		c := currentConfig()
+		cutoff := time.Now().Add(-expiry())
+		expired := []Device{}
+		mutex.Lock()
+		for address, device := range devices {
+			if device.Detected.After(cutoff) {
+				continue
+			}
+			delete(devices, address)
+			delete(histories, address)
+			delete(packetCounters, address)
+			if device.Detected.After(last) {
+				expired = append(expired, device)
+			}
+		}
+		expireGroups(cutoff)
+		expireTrackers(cutoff)
+		expireExposureNotifications(cutoff)
+		expireRules()
+		departures := checkPresence(time.Now(), c)
+		mutex.Unlock()
+		for _, device := range expired {
+			deviceRSSI.DeleteLabelValues(device.Address)
+			broker.Publish(Event{Type: EventExpire, Device: device})
+		}
+		for _, e := range departures {
+			logger.Println(e.Presence.Name, "departed")
+			broker.Publish(e)
+		}
+		last = cutoff
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the columns of the CSV exports
+var deviceColumns = []string{"address", "name", "vendor", "rssi", "txpower", "firstseen", "detected", "battery", "advertisement", "scanresponse"}
+var historyColumns = []string{"address", "name", "rssi", "detected", "advertisement", "scanresponse"}
+
+// Snapshot is everything blueblue knows about the devices at a point in
+// time, for archiving
+type Snapshot struct {
+	Taken     time.Time           `json:"taken"`
+	Version   string              `json:"version"`
+	Devices   []Device            `json:"devices"`
+	Histories map[string][]Sample `json:"histories"`
+}
+
+// take a snapshot of all known devices, strongest first, and their histories
+func takeSnapshot() Snapshot {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	s := Snapshot{Taken: time.Now(), Version: version, Devices: []Device{}, Histories: map[string][]Sample{}}
+	for _, device := range devices {
+		device.Since = strconv.Itoa(int(time.Since(device.Detected).Seconds()))
+		recentPackets(&device)
+		s.Devices = append(s.Devices, device)
+	}
+	sort.SliceStable(s.Devices, func(i, j int) bool {
+		return s.Devices[i].SmoothedRSSI > s.Devices[j].SmoothedRSSI
+	})
+	for address, ring := range histories {
+		s.Histories[address] = ring.Samples()
+	}
+	return s
+}
+
+// handler to export the visible devices, or with history=true every
+// advertisement kept in their histories, as CSV, or a snapshot of all known
+// devices and their histories as JSON
+func apiExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "json" {
+		snapshot := takeSnapshot()
+		w.Header().Set("Content-Disposition",
+			`attachment; filename="blueblue-snapshot-`+snapshot.Taken.Format("20060102-150405")+`.json"`)
+		writeJSON(w, http.StatusOK, snapshot)
+		return
+	}
+	if format != "" && format != "csv" {
+		writeError(w, http.StatusBadRequest, errors.New("unknown format "+format+", must be csv or json"))
+		return
+	}
+	rows := [][]string{deviceColumns}
+	name := "devices"
+	if r.URL.Query().Get("history") == "true" {
+		rows = append([][]string{historyColumns}, historyRows()...)
+		name = "history"
+	} else {
+		for _, device := range deviceList() {
+			battery, txPower := "", ""
+			if device.Battery != nil {
+				battery = strconv.Itoa(device.Battery.Level)
+			}
+			if device.TxPower != nil {
+				txPower = strconv.Itoa(*device.TxPower)
+			}
+			rows = append(rows, []string{device.Address, device.Name, device.Vendor, strconv.Itoa(device.RSSI), txPower,
+				device.FirstSeen.Format(time.RFC3339), device.Detected.Format(time.RFC3339), battery, strings.TrimSpace(device.Advertisement), strings.TrimSpace(device.ScanResponse)})
+		}
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="blueblue-`+name+`.csv"`)
+	writer := csv.NewWriter(w)
+	err := writer.WriteAll(rows)
+	if err != nil {
+		logger.Println("Cannot write CSV:", err)
+	}
+}
+
+// the advertisements in the histories of all devices, oldest first
+func historyRows() [][]string {
+	type entry struct {
+		address string
+		sample  Sample
+	}
+	mutex.RLock()
+	defer mutex.RUnlock()
+	entries := []entry{}
+	for address, ring := range histories {
+		for _, sample := range ring.Samples() {
+			entries = append(entries, entry{address, sample})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].sample.Detected.Before(entries[j].sample.Detected)
+	})
+	rows := [][]string{}
+	for _, e := range entries {
+		rows = append(rows, []string{e.address, devices[e.address].Name, strconv.Itoa(e.sample.RSSI),
+			e.sample.Detected.Format(time.RFC3339Nano), strings.TrimSpace(e.sample.Advertisement), strings.TrimSpace(e.sample.ScanResponse)})
+	}
+	return rows
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// Exposure Notification service UUID
+var exposureNotificationUUID = ble.UUID16(0xfd6f)
+
+// when each address advertising Exposure Notifications was last seen,
+// protected by the device mutex. They're counted instead of listed as
+// devices, since phones rotate their address with the rolling proximity
+// identifier every 10 to 20 minutes
+var exposureNotifications = make(map[string]time.Time)
+
+// whether the advertisement is an Exposure Notification
+func isExposureNotification(a ble.Advertisement) bool {
+	for _, sd := range a.ServiceData() {
+		if sd.UUID.Equal(exposureNotificationUUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// the number of addresses that advertised Exposure Notifications since the
+// cutoff
+func exposureNotificationsSince(cutoff time.Time) int {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	count := 0
+	for _, detected := range exposureNotifications {
+		if detected.After(cutoff) {
+			count++
+		}
+	}
+	return count
+}
+
+// forget the addresses that haven't advertised Exposure Notifications since
+// the cutoff, must be called with the device mutex held
+func expireExposureNotifications(cutoff time.Time) {
+	for address, detected := range exposureNotifications {
+		if detected.Before(cutoff) {
+			delete(exposureNotifications, address)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sausheong/ble"
+)
+
+// Peer is a device blueblue is connected to over GATT
+type Peer struct {
+	Client    ble.Client
+	Connected time.Time
+
+	mutex         sync.Mutex
+	subscriptions map[string]map[chan Value]bool
+}
+
+// Connection describes a GATT connection
+type Connection struct {
+	Address      string    `json:"address"`
+	LocalAddress string    `json:"localaddress"`
+	Connected    time.Time `json:"connected"`
+	RxMTU        int       `json:"rxmtu"`
+	TxMTU        int       `json:"txmtu"`
+	RSSI         int       `json:"rssi"`
+}
+
+// GATTService is a discovered GATT service
+type GATTService struct {
+	UUID            string               `json:"uuid"`
+	Name            string               `json:"name,omitempty"`
+	Handle          uint16               `json:"handle"`
+	Characteristics []GATTCharacteristic `json:"characteristics"`
+}
+
+// GATTCharacteristic is a discovered GATT characteristic
+type GATTCharacteristic struct {
+	UUID        string           `json:"uuid"`
+	Name        string           `json:"name,omitempty"`
+	Handle      uint16           `json:"handle"`
+	ValueHandle uint16           `json:"valuehandle"`
+	Properties  []string         `json:"properties"`
+	Descriptors []GATTDescriptor `json:"descriptors"`
+}
+
+// GATTDescriptor is a discovered GATT descriptor
+type GATTDescriptor struct {
+	UUID   string `json:"uuid"`
+	Name   string `json:"name,omitempty"`
+	Handle uint16 `json:"handle"`
+}
+
+// Value is the value of a characteristic, raw and decoded
+type Value struct {
+	UUID    string      `json:"uuid"`
+	Name    string      `json:"name,omitempty"`
+	Hex     string      `json:"hex"`
+	Decoded interface{} `json:"decoded,omitempty"`
+}
+
+// DeviceInfo is the content of a device's Device Information Service
+type DeviceInfo struct {
+	Manufacturer     string    `json:"manufacturer,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	Serial           string    `json:"serial,omitempty"`
+	HardwareRevision string    `json:"hardwarerevision,omitempty"`
+	FirmwareRevision string    `json:"firmwarerevision,omitempty"`
+	SoftwareRevision string    `json:"softwarerevision,omitempty"`
+	Identified       time.Time `json:"identified"`
+}
+
+// names of the characteristic properties
+var propertyNames = []struct {
+	Property ble.Property
+	Name     string
+}{
+	{ble.CharBroadcast, "broadcast"},
+	{ble.CharRead, "read"},
+	{ble.CharWriteNR, "write-without-response"},
+	{ble.CharWrite, "write"},
+	{ble.CharNotify, "notify"},
+	{ble.CharIndicate, "indicate"},
+	{ble.CharSignedWrite, "signed-write"},
+	{ble.CharExtended, "extended-properties"},
+}
+
+// connected peers by address
+var peers = make(map[string]*Peer)
+var peersMutex sync.Mutex
+
+// held while connecting, since the adapter can only connect to one device at
+// a time, so the peers aren't locked for as long as connecting takes
+var dialMutex sync.Mutex
+
+// the connected peer with the given address, if there is one
+func connectedPeer(address string) (*Peer, bool) {
+	peersMutex.Lock()
+	defer peersMutex.Unlock()
+	peer, ok := peers[address]
+	return peer, ok
+}
+
+// connect to the device with the given address, or return the existing
+// connection if there is one
+func connect(address string, timeout time.Duration) (*Peer, error) {
+	if peer, ok := connectedPeer(address); ok {
+		return peer, nil
+	}
+	if bleDevice == nil {
+		return nil, errNoHCI
+	}
+	dialMutex.Lock()
+	defer dialMutex.Unlock()
+	// it may have been connected to while waiting
+	if peer, ok := connectedPeer(address); ok {
+		return peer, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	client, err := ble.Dial(ctx, ble.NewAddr(address))
+	if err != nil {
+		return nil, err
+	}
+	_, err = client.ExchangeMTU(ble.MaxMTU)
+	if err != nil {
+		logger.Println("Cannot exchange MTU with", address, err)
+	}
+	peer := &Peer{
+		Client:        client,
+		Connected:     time.Now(),
+		subscriptions: make(map[string]map[chan Value]bool),
+	}
+	peersMutex.Lock()
+	peers[address] = peer
+	peersMutex.Unlock()
+	logger.Println("Connected to", address)
+
+	go func() {
+		<-client.Disconnected()
+		peersMutex.Lock()
+		delete(peers, address)
+		peersMutex.Unlock()
+		peer.closeSubscriptions()
+		logger.Println("Disconnected from", address)
+	}()
+	return peer, nil
+}
+
+// check if there is a connection to the device with the given address
+func isConnected(address string) bool {
+	_, ok := connectedPeer(address)
+	return ok
+}
+
+// disconnect from the device with the given address
+func disconnect(address string) bool {
+	peer, ok := connectedPeer(address)
+	if !ok {
+		return false
+	}
+	err := peer.Client.CancelConnection()
+	if err != nil {
+		logger.Println("Cannot disconnect from", address, err)
+	}
+	return true
+}
+
+// describe the peer's connection
+func (p *Peer) Connection() Connection {
+	conn := p.Client.Conn()
+	return Connection{
+		Address:      conn.RemoteAddr().String(),
+		LocalAddress: conn.LocalAddr().String(),
+		Connected:    p.Connected,
+		RxMTU:        conn.RxMTU(),
+		TxMTU:        conn.TxMTU(),
+		RSSI:         p.Client.ReadRSSI(),
+	}
+}
+
+// discover the GATT profile of the peer, using the cached profile unless
+// refresh is set
+func (p *Peer) Services(refresh bool) ([]GATTService, error) {
+	profile, err := p.Client.DiscoverProfile(refresh)
+	if err != nil {
+		return nil, err
+	}
+	services := []GATTService{}
+	for _, s := range profile.Services {
+		service := GATTService{
+			UUID:            uuidString(s.UUID),
+			Name:            ble.Name(s.UUID),
+			Handle:          s.Handle,
+			Characteristics: []GATTCharacteristic{},
+		}
+		for _, c := range s.Characteristics {
+			characteristic := GATTCharacteristic{
+				UUID:        uuidString(c.UUID),
+				Name:        ble.Name(c.UUID),
+				Handle:      c.Handle,
+				ValueHandle: c.ValueHandle,
+				Properties:  properties(c.Property),
+				Descriptors: []GATTDescriptor{},
+			}
+			for _, d := range c.Descriptors {
+				characteristic.Descriptors = append(characteristic.Descriptors, GATTDescriptor{
+					UUID:   uuidString(d.UUID),
+					Name:   ble.Name(d.UUID),
+					Handle: d.Handle,
+				})
+			}
+			service.Characteristics = append(service.Characteristics, characteristic)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// names of the properties that are set
+func properties(property ble.Property) []string {
+	names := []string{}
+	for _, p := range propertyNames {
+		if property&p.Property != 0 {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// format a UUID the way it's usually written
+func uuidString(u ble.UUID) string {
+	if u.Len() == 16 {
+		return formatUUID(ble.Reverse(u))
+	}
+	return u.String()
+}
+
+// find a characteristic in the peer's GATT profile
+func (p *Peer) Characteristic(service, characteristic string) (*ble.Characteristic, error) {
+	su, err := ble.Parse(service)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service UUID %q: %v", service, err)
+	}
+	cu, err := ble.Parse(characteristic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid characteristic UUID %q: %v", characteristic, err)
+	}
+	profile, err := p.Client.DiscoverProfile(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range profile.Services {
+		if !s.UUID.Equal(su) {
+			continue
+		}
+		for _, c := range s.Characteristics {
+			if c.UUID.Equal(cu) {
+				return c, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("characteristic %s not found in service %s", characteristic, service)
+}
+
+// characteristics with a single unsigned integer value
+var integerCharacteristics = map[string]bool{
+	"2a19": true, // Battery Level
+	"2a01": true, // Appearance
+}
+
+// create the value of a characteristic, decoding it on a best-effort basis:
+// known integer characteristics and short values as unsigned little-endian
+// integers, printable values as strings
+func newValue(u ble.UUID, value []byte) Value {
+	v := Value{
+		UUID: uuidString(u),
+		Name: ble.Name(u),
+		Hex:  hex.EncodeToString(value),
+	}
+	switch {
+	case integerCharacteristics[u.String()] && len(value) > 0 && len(value) <= 8:
+		v.Decoded = littleEndian(value)
+	case printable(value):
+		v.Decoded = strings.TrimRight(string(value), "\x00")
+	case len(value) > 0 && len(value) <= 8:
+		v.Decoded = littleEndian(value)
+	}
+	return v
+}
+
+// decode up to 8 bytes as an unsigned little-endian integer
+func littleEndian(b []byte) uint64 {
+	buf := make([]byte, 8)
+	copy(buf, b)
+	return binary.LittleEndian.Uint64(buf)
+}
+
+// check if the value is a printable UTF-8 string
+func printable(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && r != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// subscribe to notifications (or indications) of the characteristic, the
+// values are sent to the returned channel until unsubscribed or the peer
+// disconnects
+func (p *Peer) Subscribe(c *ble.Characteristic, indicate bool) (chan Value, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := c.UUID.String()
+	subscribers, ok := p.subscriptions[key]
+	if !ok {
+		subscribers = make(map[chan Value]bool)
+		err := p.Client.Subscribe(c, indicate, func(data []byte) {
+			value := newValue(c.UUID, data)
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			for ch := range subscribers {
+				select {
+				case ch <- value:
+				default:
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.subscriptions[key] = subscribers
+	}
+	ch := make(chan Value, 64)
+	subscribers[ch] = true
+	return ch, nil
+}
+
+// stop sending notifications of the characteristic to the channel,
+// unsubscribing from the peer when there are no subscribers left
+func (p *Peer) Unsubscribe(c *ble.Characteristic, indicate bool, ch chan Value) {
+	p.mutex.Lock()
+	key := c.UUID.String()
+	subscribers, ok := p.subscriptions[key]
+	if !ok || !subscribers[ch] {
+		p.mutex.Unlock()
+		return
+	}
+	delete(subscribers, ch)
+	close(ch)
+	last := len(subscribers) == 0
+	if last {
+		delete(p.subscriptions, key)
+	}
+	// don't hold the lock while the notification handler may be waiting on it
+	p.mutex.Unlock()
+
+	if last {
+		err := p.Client.Unsubscribe(c, indicate)
+		if err != nil {
+			logger.Println("Cannot unsubscribe from", key, err)
+		}
+	}
+}
+
+// close all subscriber channels
+func (p *Peer) closeSubscriptions() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for key, subscribers := range p.subscriptions {
+		for ch := range subscribers {
+			close(ch)
+		}
+		delete(p.subscriptions, key)
+	}
+}
+
+// read the Device Information Service of the peer, characteristics the
+// peer doesn't have are left empty
+func (p *Peer) Identify() (*DeviceInfo, error) {
+	info := &DeviceInfo{Identified: time.Now()}
+	fields := []struct {
+		UUID  string
+		Field *string
+	}{
+		{"2a29", &info.Manufacturer},
+		{"2a24", &info.Model},
+		{"2a25", &info.Serial},
+		{"2a27", &info.HardwareRevision},
+		{"2a26", &info.FirmwareRevision},
+		{"2a28", &info.SoftwareRevision},
+	}
+	found := false
+	for _, f := range fields {
+		c, err := p.Characteristic("180a", f.UUID)
+		if err != nil {
+			continue
+		}
+		value, err := p.Client.ReadCharacteristic(c)
+		if err != nil {
+			logger.Println("Cannot read", f.UUID, "from", p.Client.Addr(), err)
+			continue
+		}
+		*f.Field = clean(strings.TrimRight(string(value), "\x00"))
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no device information available")
+	}
+	return info, nil
+}
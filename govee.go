@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/sausheong/ble"
+)
+
+// company IDs in the manufacturer data of Govee thermometers, the H5102 and
+// others use the one assigned to Nokia
+const (
+	goveeCompany      = 0xec88
+	goveeNokiaCompany = 0x0001
+)
+
+func init() {
+	registerManufacturerDataDecoder(goveeCompany, "govee", decodeGoveeH5074)
+	registerManufacturerDataDecoder(goveeCompany, "govee", decodeGoveeH5075)
+	registerGuardedManufacturerDataDecoder(goveeNokiaCompany, "govee", isGovee, decodeGoveeH5102)
+}
+
+// check the advertisement is from a Govee device, by its name or the service
+// UUID Govee advertises, since other devices use the Nokia company ID too
+func isGovee(a ble.Advertisement) bool {
+	name := a.LocalName()
+	if strings.HasPrefix(name, "GVH") || strings.HasPrefix(name, "Govee") {
+		return true
+	}
+	for _, uuid := range a.Services() {
+		if uuid.Equal(ble.UUID16(goveeCompany)) {
+			return true
+		}
+	}
+	return false
+}
+
+// decode the H5074 format, temperature and humidity in hundredths and
+// battery in percent, little-endian
+func decodeGoveeH5074(data []byte, sensor *Sensor) bool {
+	if len(data) != 7 {
+		return false
+	}
+	temperature := float64(int16(binary.LittleEndian.Uint16(data[1:]))) / 100
+	humidity := float64(binary.LittleEndian.Uint16(data[3:])) / 100
+	battery := int(data[5])
+	sensor.Temperature, sensor.Humidity, sensor.Battery = &temperature, &humidity, &battery
+	return true
+}
+
+// decode the H5072 and H5075 format, temperature and humidity packed into
+// three bytes followed by battery in percent, whose top bit is a flag
+func decodeGoveeH5075(data []byte, sensor *Sensor) bool {
+	if len(data) != 6 {
+		return false
+	}
+	return decodeGoveePacked(data[1:4], data[4]&0x7f, sensor)
+}
+
+// decode the H5101, H5102 and H5177 format, the same as the H5075 but a
+// byte later
+func decodeGoveeH5102(data []byte, sensor *Sensor) bool {
+	if len(data) != 6 {
+		return false
+	}
+	return decodeGoveePacked(data[2:5], data[5]&0x7f, sensor)
+}
+
+// decode temperature and humidity packed into a big-endian number, as the
+// temperature in tenths times 1000 plus humidity in tenths, with the top bit
+// set when the temperature is negative
+func decodeGoveePacked(packed []byte, level byte, sensor *Sensor) bool {
+	value := uint32(packed[0])<<16 | uint32(packed[1])<<8 | uint32(packed[2])
+	negative := value&0x800000 != 0
+	value &= 0x7fffff
+	temperature := float64(value/1000) / 10
+	if negative {
+		temperature = -temperature
+	}
+	humidity := float64(value%1000) / 10
+	if humidity > 100 || level > 100 {
+		return false
+	}
+	battery := int(level)
+	sensor.Temperature, sensor.Humidity, sensor.Battery = &temperature, &humidity, &battery
+	return true
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestDecodeGoveeH5074(t *testing.T) {
+	testDecoder(t, decodeGoveeH5074, []decoderTest{
+		{"readings", "00 3408 8e13 64 02", map[string]float64{"temperature": 21, "humidity": 50.06, "battery": 100}},
+		{"below zero", "00 0cfe 8e13 64 02", map[string]float64{"temperature": -5, "humidity": 50.06, "battery": 100}},
+		{"truncated", "00 3408 8e13 64", nil},
+	})
+}
+
+func TestDecodeGoveeH5075(t *testing.T) {
+	testDecoder(t, decodeGoveeH5075, []decoderTest{
+		{"readings", "00 036d56 64 00", map[string]float64{"temperature": 22.4, "humidity": 59.8, "battery": 100}},
+		{"below zero", "00 80279b 50 00", map[string]float64{"temperature": -1, "humidity": 13.9, "battery": 80}},
+		{"battery flag", "00 036d56 e4 00", map[string]float64{"temperature": 22.4, "humidity": 59.8, "battery": 100}},
+		{"battery out of range", "00 036d56 7f 00", nil},
+		{"truncated", "00 036d56 64", nil},
+		{"H5074", "00 3408 8e13 64 02", nil},
+	})
+}
+
+func TestDecodeGoveeH5102(t *testing.T) {
+	testDecoder(t, decodeGoveeH5102, []decoderTest{
+		{"readings", "01 01 036d56 64", map[string]float64{"temperature": 22.4, "humidity": 59.8, "battery": 100}},
+		{"below zero", "01 01 80279b 50", map[string]float64{"temperature": -1, "humidity": 13.9, "battery": 80}},
+		{"truncated", "01 01 036d56", nil},
+	})
+}
+
+func TestDecodeSensorGoveeNokiaCompany(t *testing.T) {
+	data := []byte{0x01, 0x00, 0x01, 0x01, 0x03, 0x6d, 0x56, 0x64}
+	tests := []struct {
+		name       string
+		structures [][]byte
+		want       bool
+	}{
+		{"name", [][]byte{adStructure(adCompleteName, 'G', 'V', 'H', '5', '1', '0', '2'), adStructure(adManufacturerData, data...)}, true},
+		{"service", [][]byte{adStructure(adComplete16, 0x88, 0xec), adStructure(adManufacturerData, data...)}, true},
+		{"other device", [][]byte{adStructure(adCompleteName, 'L', 'a', 'm', 'p'), adStructure(adManufacturerData, data...)}, false},
+		{"no name", [][]byte{adStructure(adManufacturerData, data...)}, false},
+	}
+	for _, test := range tests {
+		sensor := decodeSensor(testAdvertisement(test.structures...), nil)
+		if got := sensor != nil && sensor.Format == "govee"; got != test.want {
+			t.Errorf("%s: got %+v, want decoded %v", test.name, sensor, test.want)
+		}
+	}
+}
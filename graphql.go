@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// a JSON scalar for values that don't map onto GraphQL types, such as the
+// adapters' sightings, which are passed through as they are
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Any JSON value",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+})
+
+// graphqlTypes collects the GraphQL object types made from Go types, by name
+type graphqlTypes map[string]*graphql.Object
+
+// the GraphQL type of a Go type, made from its fields and JSON tags
+func (types graphqlTypes) of(t reflect.Type) graphql.Output {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return graphql.DateTime
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return graphql.Int
+	case reflect.Float32, reflect.Float64:
+		return graphql.Float
+	case reflect.String:
+		return graphql.String
+	case reflect.Slice, reflect.Array:
+		return graphql.NewList(types.of(t.Elem()))
+	case reflect.Struct:
+		if object, ok := types[t.Name()]; ok {
+			return object
+		}
+		fields := graphql.Fields{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if f.PkgPath != "" || tag == "-" || tag == "" {
+				continue
+			}
+			fields[tag] = &graphql.Field{Type: types.of(f.Type)}
+		}
+		object := graphql.NewObject(graphql.ObjectConfig{Name: t.Name(), Fields: fields})
+		types[t.Name()] = object
+		return object
+	}
+	return jsonScalar
+}
+
+// the arguments to filter devices by
+var deviceFilterArgs = graphql.FieldConfigArgument{
+	"minRssi": &graphql.ArgumentConfig{Type: graphql.Int, Description: "weakest RSSI"},
+	"maxRssi": &graphql.ArgumentConfig{Type: graphql.Int, Description: "strongest RSSI"},
+	"name":    &graphql.ArgumentConfig{Type: graphql.String, Description: "part of the name, ignoring case"},
+	"since":   &graphql.ArgumentConfig{Type: graphql.DateTime, Description: "last seen at or after"},
+}
+
+// check if the device passes the filters in the arguments
+func filterDevice(device Device, args map[string]interface{}) bool {
+	if min, ok := args["minRssi"].(int); ok && device.RSSI < min {
+		return false
+	}
+	if max, ok := args["maxRssi"].(int); ok && device.RSSI > max {
+		return false
+	}
+	if name, ok := args["name"].(string); ok && !strings.Contains(strings.ToLower(device.Name), strings.ToLower(name)) {
+		return false
+	}
+	if since, ok := args["since"].(time.Time); ok && device.Detected.Before(since) {
+		return false
+	}
+	return true
+}
+
+// the GraphQL schema, with queries for devices and a subscription to their
+// events
+func graphqlSchema() (graphql.Schema, error) {
+	types := graphqlTypes{}
+	device := types.of(reflect.TypeOf(Device{}))
+	event := types.of(reflect.TypeOf(Event{}))
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"devices": &graphql.Field{
+				Type:        graphql.NewList(device),
+				Description: "The visible devices, strongest first",
+				Args:        deviceFilterArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					list := []Device{}
+					for _, d := range deviceList() {
+						if filterDevice(d, p.Args) {
+							list = append(list, d)
+						}
+					}
+					return list, nil
+				},
+			},
+			"device": &graphql.Field{
+				Type:        device,
+				Description: "A device by address",
+				Args: graphql.FieldConfigArgument{
+					"address": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					mutex.RLock()
+					defer mutex.RUnlock()
+					if d, ok := devices[p.Args["address"].(string)]; ok {
+						return d, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"events": &graphql.Field{
+				Type:        event,
+				Description: "New, updated and expired devices",
+				Args:        deviceFilterArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+				Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+					events := make(chan interface{})
+					go func() {
+						defer close(events)
+						ch := broker.Subscribe()
+						defer broker.Unsubscribe(ch)
+						for {
+							select {
+							case e := <-ch:
+								if !filterDevice(e.Device, p.Args) {
+									continue
+								}
+								select {
+								case events <- e:
+								case <-p.Context.Done():
+									return
+								}
+							case <-p.Context.Done():
+								return
+							}
+						}
+					}()
+					return events, nil
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Subscription: subscription})
+}
+
+var queries graphql.Schema
+
+func init() {
+	var err error
+	queries, err = graphqlSchema()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GraphQLRequest is a GraphQL query or subscription
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// handler for GraphQL queries, given as a POSTed JSON body or in the query
+// parameter of a GET. Subscriptions are streamed as server-sent events
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	req := GraphQLRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			err := json.Unmarshal([]byte(v), &req.Variables)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+	case http.MethodPost:
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	params := graphql.Params{
+		Schema:         queries,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		writeJSON(w, http.StatusOK, graphql.Do(params))
+		return
+	}
+	streamGraphQL(w, r, params)
+}
+
+// stream the results of a subscription as server-sent events until the
+// client goes away
+func streamGraphQL(w http.ResponseWriter, r *http.Request, params graphql.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+	// keep reading until the subscription closes, so it isn't left blocked
+	for result := range graphql.Subscribe(params) {
+		data, err := json.Marshal(result)
+		if err != nil {
+			logger.Println("Cannot encode GraphQL result:", err)
+			continue
+		}
+		fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	fmt.Fprint(w, "event: complete\ndata:\n\n")
+	flusher.Flush()
+}
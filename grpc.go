@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sausheong/blueblue/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// the gRPC server, if started, so it can be stopped on shutdown
+var grpcServer *grpc.Server
+
+// grpcService implements the BlueBlue gRPC service
+type grpcService struct {
+	rpc.UnimplementedBlueBlueServer
+}
+
+// ListDevices returns the visible devices, strongest first
+func (grpcService) ListDevices(ctx context.Context, req *rpc.ListDevicesRequest) (*rpc.ListDevicesResponse, error) {
+	res := &rpc.ListDevicesResponse{}
+	for _, device := range deviceList() {
+		if req.MinRssi != 0 && device.RSSI < int(req.MinRssi) {
+			continue
+		}
+		res.Devices = append(res.Devices, toProto(device))
+	}
+	return res, nil
+}
+
+// WatchDevices streams device events until the client goes away
+func (grpcService) WatchDevices(req *rpc.WatchDevicesRequest, stream rpc.BlueBlue_WatchDevicesServer) error {
+	ch := broker.Subscribe()
+	defer broker.Unsubscribe(ch)
+	for {
+		select {
+		case e := <-ch:
+			if req.MinRssi != 0 && e.Device.RSSI < int(req.MinRssi) {
+				continue
+			}
+			err := stream.Send(&rpc.DeviceEvent{Type: e.Type, Device: toProto(e.Device)})
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-shuttingDown:
+			return nil
+		}
+	}
+}
+
+// StartScan starts scanning
+func (grpcService) StartScan(ctx context.Context, req *rpc.StartScanRequest) (*rpc.ScanResponse, error) {
+	if !startScanning() {
+		return nil, status.Error(codes.FailedPrecondition, "already scanning")
+	}
+	return &rpc.ScanResponse{Scanning: true}, nil
+}
+
+// StopScan stops scanning
+func (grpcService) StopScan(ctx context.Context, req *rpc.StopScanRequest) (*rpc.ScanResponse, error) {
+	if !stopScanning() {
+		return nil, status.Error(codes.FailedPrecondition, "not scanning")
+	}
+	return &rpc.ScanResponse{Scanning: false}, nil
+}
+
+// convert a device to its protobuf message
+func toProto(device Device) *rpc.Device {
+	d := &rpc.Device{
+		Address:           device.Address,
+		AddressType:       device.AddressType,
+		PrivateAddress:    device.PrivateAddress,
+		Group:             device.Group,
+		Connectable:       device.Connectable,
+		Appearance:        device.Appearance,
+		Continuity:        device.Continuity,
+		Tags:              device.Tags,
+		FirstSeen:         timestamppb.New(device.FirstSeen),
+		Detected:          timestamppb.New(device.Detected),
+		Name:              device.Name,
+		Vendor:            device.Vendor,
+		Rssi:              int32(device.RSSI),
+		SmoothedRssi:      device.SmoothedRSSI,
+		Distance:          device.Distance,
+		Packets:           int32(device.Packets),
+		PacketsLastMinute: int32(device.PacketsLastMinute),
+		Interval:          device.Interval,
+		Adapters:          map[string]*rpc.Sighting{},
+		Advertisement:     unformatHex(device.Advertisement),
+		ScanResponse:      unformatHex(device.ScanResponse),
+	}
+	for name, sighting := range device.Adapters {
+		d.Adapters[name] = &rpc.Sighting{Rssi: int32(sighting.RSSI), Detected: timestamppb.New(sighting.Detected)}
+	}
+	for _, service := range device.Services {
+		d.Services = append(d.Services, &rpc.Service{Uuid: service.UUID, Name: service.Name})
+	}
+	if device.Tracker != nil {
+		d.Tracker = &rpc.Tracker{
+			Kind:    device.Tracker.Kind,
+			Since:   timestamppb.New(device.Tracker.Since),
+			Known:   device.Tracker.Known,
+			Alerted: device.Tracker.Alerted,
+		}
+	}
+	if device.Sensor != nil {
+		d.Sensor = toProtoSensor(device.Sensor)
+	}
+	if device.TxPower != nil {
+		power := int32(*device.TxPower)
+		d.TxPower = &power
+	}
+	if device.Battery != nil {
+		d.Battery = &rpc.Battery{Level: int32(device.Battery.Level), Read: timestamppb.New(device.Battery.Read)}
+	}
+	return d
+}
+
+// convert sensor readings to their protobuf message
+func toProtoSensor(sensor *Sensor) *rpc.Sensor {
+	s := &rpc.Sensor{
+		Format:      sensor.Format,
+		Temperature: sensor.Temperature,
+		Humidity:    sensor.Humidity,
+		Pressure:    sensor.Pressure,
+		Voltage:     sensor.Voltage,
+		Buttons:     sensor.Buttons,
+		Values:      sensor.Values,
+	}
+	if sensor.Battery != nil {
+		battery := int32(*sensor.Battery)
+		s.Battery = &battery
+	}
+	return s
+}
+
+// the bytes of hex formatted by formatHex
+func unformatHex(s string) []byte {
+	b, _ := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	return b
+}
+
+// the role of the caller, from the bearer token or basic auth credentials in
+// the authorization metadata
+func grpcRole(ctx context.Context) (role string, ok bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		switch {
+		case strings.HasPrefix(auth, "Bearer "):
+			token := strings.TrimPrefix(auth, "Bearer ")
+			if tokenIn(token, *apiTokens) {
+				return RoleAdmin, true
+			}
+			if tokenIn(token, *viewerTokens) {
+				return RoleViewer, true
+			}
+		case strings.HasPrefix(auth, "Basic "):
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+			if err != nil {
+				continue
+			}
+			user, password, found := strings.Cut(string(decoded), ":")
+			if found && validCredentials(user, password) {
+				return userRole(user), true
+			}
+		}
+	}
+	return "", false
+}
+
+// check the caller may call the method, the same way as the JSON API, so
+// viewers can list and watch devices but not start or stop scanning
+func grpcAuthorize(ctx context.Context, method string) error {
+	if *apiTokens == "" && *viewerTokens == "" && *basicAuth == "" && *htpasswdPath == "" {
+		return nil
+	}
+	role, ok := grpcRole(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "not authenticated")
+	}
+	if role != RoleAdmin && method != rpc.BlueBlue_ListDevices_FullMethodName &&
+		method != rpc.BlueBlue_WatchDevices_FullMethodName {
+		return status.Error(codes.PermissionDenied, "only admins can do this")
+	}
+	return nil
+}
+
+// start the gRPC server, over TLS if there are certificates
+func startGRPC(address string) error {
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			err := grpcAuthorize(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			err := grpcAuthorize(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+	if *tlsCert != "" || *tlsKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(*tlsCert, *tlsKey)
+		if err != nil {
+			return err
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	grpcServer = grpc.NewServer(options...)
+	rpc.RegisterBlueBlueServer(grpcServer, grpcService{})
+	go func() {
+		err := grpcServer.Serve(listener)
+		if err != nil {
+			logger.Println("gRPC server failed:", err)
+		}
+	}()
+	fmt.Println("Started blueblue gRPC server at", address)
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// devices that have already been announced to Home Assistant
+var announced = make(map[string]bool)
+
+// HADevice is the device registry entry in a Home Assistant discovery payload
+type HADevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+	ViaDevice   string   `json:"via_device,omitempty"`
+}
+
+// HAConfig is a Home Assistant MQTT discovery payload
+type HAConfig struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	StateTopic          string   `json:"state_topic"`
+	JSONAttributesTopic string   `json:"json_attributes_topic,omitempty"`
+	PayloadHome         string   `json:"payload_home,omitempty"`
+	PayloadNotHome      string   `json:"payload_not_home,omitempty"`
+	SourceType          string   `json:"source_type,omitempty"`
+	DeviceClass         string   `json:"device_class,omitempty"`
+	UnitOfMeasurement   string   `json:"unit_of_measurement,omitempty"`
+	ValueTemplate       string   `json:"value_template,omitempty"`
+	Device              HADevice `json:"device"`
+}
+
+// publish the Home Assistant discovery payloads and tracker state for the
+// device in the event
+func publishHomeAssistant(client mqtt.Client, e Event) {
+	c := currentConfig()
+	topic := c.MQTTTopic + "/devices/" + e.Device.Address
+	if e.Type == EventExpire {
+		client.Publish(topic+"/state", byte(c.MQTTQoS), true, "not_home")
+		return
+	}
+	if !announced[e.Device.Address] {
+		announceHomeAssistant(client, e.Device, topic)
+		announced[e.Device.Address] = true
+	}
+	client.Publish(topic+"/state", byte(c.MQTTQoS), true, "home")
+}
+
+// send the discovery payloads for the device tracker and RSSI sensor
+func announceHomeAssistant(client mqtt.Client, device Device, topic string) {
+	id := *mqttClientID + "_" + strings.ReplaceAll(device.Address, ":", "")
+	name := device.Name
+	if name == "" {
+		name = device.Address
+	}
+	haDevice := HADevice{
+		Identifiers: []string{id},
+		Name:        name,
+		ViaDevice:   *mqttClientID,
+	}
+	tracker := HAConfig{
+		Name:                name,
+		UniqueID:            id,
+		StateTopic:          topic + "/state",
+		JSONAttributesTopic: topic,
+		PayloadHome:         "home",
+		PayloadNotHome:      "not_home",
+		SourceType:          "bluetooth_le",
+		Device:              haDevice,
+	}
+	rssi := HAConfig{
+		Name:                name + " RSSI",
+		UniqueID:            id + "_rssi",
+		StateTopic:          topic,
+		JSONAttributesTopic: topic,
+		DeviceClass:         "signal_strength",
+		UnitOfMeasurement:   "dBm",
+		ValueTemplate:       "{{ value_json.rssi }}",
+		Device:              haDevice,
+	}
+	publishConfig(client, *mqttHAPrefix+"/device_tracker/"+id+"/config", tracker)
+	publishConfig(client, *mqttHAPrefix+"/sensor/"+id+"_rssi/config", rssi)
+}
+
+// publish a retained discovery payload
+func publishConfig(client mqtt.Client, topic string, config HAConfig) {
+	payload, err := json.Marshal(config)
+	if err != nil {
+		logger.Println("Cannot encode Home Assistant config:", err)
+		return
+	}
+	client.Publish(topic, byte(currentConfig().MQTTQoS), true, payload)
+}
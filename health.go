@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// handler for liveness probes, blueblue is alive if it can answer
+func healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// handler for readiness probes, blueblue is ready once an adapter is open
+// and working, that is, not being recovered or unplugged
+func readyz(w http.ResponseWriter, r *http.Request) {
+	adaptersMutex.Lock()
+	statuses := adapterStatuses()
+	adaptersMutex.Unlock()
+	for _, status := range statuses {
+		if status.State == AdapterOK {
+			fmt.Fprintln(w, "ok")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintln(w, "no working adapter")
+}
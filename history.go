@@ -0,0 +1,76 @@
+package main
+
+import "time"
+
+// Sample is a single advertisement received from a device
+type Sample struct {
+	Detected      time.Time `json:"detected"`
+	RSSI          int       `json:"rssi"`
+	Advertisement string    `json:"advertisement"`
+	ScanResponse  string    `json:"scanresponse"`
+	// the Apple Continuity messages in the advertisement
+	Continuity []string `json:"continuity,omitempty"`
+}
+
+// Ring is a bounded buffer keeping the most recent samples
+type Ring struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+// histories of each device, protected by the device mutex
+var histories = make(map[string]*Ring)
+
+// NewRing creates a ring buffer holding up to size samples
+func NewRing(size int) *Ring {
+	return &Ring{samples: make([]Sample, size)}
+}
+
+// Add a sample, overwriting the oldest one if the buffer is full
+func (r *Ring) Add(s Sample) {
+	if len(r.samples) == 0 {
+		return
+	}
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Samples returns a copy of the samples, oldest first
+func (r *Ring) Samples() []Sample {
+	if !r.full {
+		return append([]Sample{}, r.samples[:r.next]...)
+	}
+	return append(append([]Sample{}, r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// add the device's latest advertisement to its history, must be called
+// with the device mutex held
+func recordHistory(device Device) {
+	ring, ok := histories[device.Address]
+	if !ok {
+		ring = NewRing(*historySize)
+		histories[device.Address] = ring
+	}
+	ring.Add(Sample{
+		Detected:      device.Detected,
+		RSSI:          device.RSSI,
+		Advertisement: device.Advertisement,
+		ScanResponse:  device.ScanResponse,
+		Continuity:    device.Continuity,
+	})
+}
+
+// return the history of the device with the given address
+func deviceHistory(address string) (samples []Sample, ok bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	ring, ok := histories[address]
+	if !ok {
+		return
+	}
+	return ring.Samples(), true
+}
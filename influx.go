@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// escape a tag value for the InfluxDB line protocol
+var tagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// collect device samples and write them to InfluxDB at every interval, until
+// shutdown
+func writeInflux() {
+	defer flushing.Done()
+	ch := broker.Subscribe()
+	defer broker.Unsubscribe(ch)
+	scanner := tagEscaper.Replace(hostname)
+	buf := &bytes.Buffer{}
+	ticker := time.NewTicker(*influxInterval)
+	for {
+		select {
+		case e := <-ch:
+			address := tagEscaper.Replace(e.Device.Address)
+			if e.Type == EventExpire {
+				fmt.Fprintf(buf, "ble_device,address=%s,scanner=%s present=false %d\n",
+					address, scanner, time.Now().UnixNano())
+			} else {
+				fmt.Fprintf(buf, "ble_device,address=%s,scanner=%s rssi=%di,present=true %d\n",
+					address, scanner, e.Device.RSSI, e.Device.Detected.UnixNano())
+			}
+		case <-shuttingDown:
+			if buf.Len() > 0 {
+				postInflux(buf.Bytes())
+			}
+			return
+		case <-ticker.C:
+			if buf.Len() == 0 {
+				continue
+			}
+			go postInflux(buf.Bytes())
+			buf = &bytes.Buffer{}
+		}
+	}
+}
+
+// post a batch of lines to the InfluxDB write endpoint
+func postInflux(lines []byte) {
+	req, err := newInfluxRequest(lines)
+	if err != nil {
+		logger.Println("Cannot create InfluxDB request:", err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.Println("Cannot write to InfluxDB:", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		logger.Println("InfluxDB write failed:", resp.Status, string(body))
+	}
+}
+
+// create the write request for the configured InfluxDB version
+func newInfluxRequest(lines []byte) (req *http.Request, err error) {
+	query := url.Values{}
+	query.Set("precision", "ns")
+	var endpoint string
+	switch *influxVersion {
+	case 1:
+		endpoint = "/write"
+		query.Set("db", *influxDB)
+	case 2:
+		endpoint = "/api/v2/write"
+		query.Set("org", *influxOrg)
+		query.Set("bucket", *influxDB)
+	default:
+		return nil, fmt.Errorf("unsupported InfluxDB version %d", *influxVersion)
+	}
+	req, err = http.NewRequest(http.MethodPost,
+		strings.TrimSuffix(*influxURL, "/")+endpoint+"?"+query.Encode(), bytes.NewReader(lines))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if *influxToken != "" {
+		if *influxVersion == 1 {
+			user, password, _ := strings.Cut(*influxToken, ":")
+			req.SetBasicAuth(user, password)
+		} else {
+			req.Header.Set("Authorization", "Token "+*influxToken)
+		}
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sausheong/ble"
+)
+
+// an identity of one of the user's devices, whose resolvable private
+// addresses are resolved with its Identity Resolving Key (IRK)
+type identity struct {
+	name string
+	// the IRK as given and reversed, since tools don't agree on the byte
+	// order, BlueZ writes it least significant byte first
+	blocks []cipher.Block
+}
+
+var identities []identity
+
+// the identity each resolvable private address seen resolved to, "" if none,
+// protected by the device mutex
+var resolvedAddresses = map[string]string{}
+
+// load a file of IRKs, one per line as the name of the identity followed by
+// the IRK in hex, like my-phone 0123456789abcdef0123456789abcdef
+func loadIRKs(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return fmt.Errorf("no IRK for %s", line)
+		}
+		name := strings.Join(fields[:len(fields)-1], " ")
+		key, err := hex.DecodeString(strings.ReplaceAll(fields[len(fields)-1], ":", ""))
+		if err != nil || len(key) != 16 {
+			return fmt.Errorf("invalid IRK for %s, must be 16 bytes in hex", name)
+		}
+		id := identity{name: name}
+		for _, k := range [][]byte{key, ble.Reverse(key)} {
+			block, err := aes.NewCipher(k)
+			if err != nil {
+				return err
+			}
+			id.blocks = append(id.blocks, block)
+		}
+		identities = append(identities, id)
+	}
+	logger.Println("Loaded", len(identities), "IRKs")
+	return scanner.Err()
+}
+
+// the name of the identity the resolvable private address belongs to, or ""
+// if it doesn't resolve with any of the IRKs. Must be called with the device
+// mutex held
+func resolveAddress(address string) string {
+	if len(identities) == 0 {
+		return ""
+	}
+	if name, ok := resolvedAddresses[address]; ok {
+		return name
+	}
+	// addresses rotate, so forget them all now and then
+	if len(resolvedAddresses) > 10000 {
+		resolvedAddresses = map[string]string{}
+	}
+	name := ""
+	mac, err := net.ParseMAC(address)
+	if err == nil && len(mac) == 6 && mac[0]>>6 == 0x01 {
+	search:
+		for _, id := range identities {
+			for _, block := range id.blocks {
+				if bytes.Equal(addressHash(block, mac[:3]), mac[3:]) {
+					name = id.name
+					break search
+				}
+			}
+		}
+	}
+	resolvedAddresses[address] = name
+	return name
+}
+
+// the hash of a resolvable private address, from its random part, with the
+// ah function [Vol 3, Part H, 2.2.2]
+func addressHash(block cipher.Block, prand []byte) []byte {
+	r := make([]byte, 16)
+	copy(r[13:], prand)
+	block.Encrypt(r, r)
+	return r[13:]
+}
@@ -1,22 +1,27 @@
 package main
 
 import (
-	"context"
 	"encoding/hex"
+	"expvar"
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sausheong/ble"
 	"github.com/sausheong/ble/linux"
 )
@@ -25,23 +30,150 @@ var dur *time.Duration
 var dir *string
 var port *int
 var logger *log.Logger
-var stop bool = true
+var hostname string
+var mqttBroker *string
+var mqttTopic *string
+var mqttQoS *int
+var mqttClientID *string
+var mqttHA *bool
+var mqttHAPrefix *string
+var mqttSensors *bool
+var dbPath *string
+var influxURL *string
+var influxVersion *int
+var influxDB *string
+var influxOrg *string
+var influxToken *string
+var influxInterval *time.Duration
+var historySize *int
+var connectTimeout *time.Duration
+var batteryPoll *time.Duration
+var batteryLow *int
+var irksPath *string
+var rssiFilter *string
+var rssiAlpha *float64
+var pathLoss *float64
+var batteryAlert *string
+var trackerTime *time.Duration
+var trackerAlert *string
+var webhooks *string
+var webhookEvents *string
+var messagesPath *string
+var telegramToken *string
+var telegramChat *string
+var telegramEvents *string
+var slackWebhook *string
+var slackEvents *string
+var discordWebhook *string
+var discordEvents *string
+var smtpServer *string
+var smtpUser *string
+var smtpPassword *string
+var emailFrom *string
+var emailTo *string
+var emailEvents *string
+var emailDigest *string
+var gattServer *bool
+var hciAdapters *string
+var adapterErrors *int
+var adapterSilence *time.Duration
+var activeScan *bool
+var scanInterval *time.Duration
+var scanWindow *time.Duration
+var whitelist *string
+var duplicates *bool
+var sleep *time.Duration
+var schedule *string
+var quietPeriod *string
+var statePath *string
+var configPath *string
+var tlsCert *string
+var tlsKey *string
+var acmeHost *string
+var acmeEmail *string
+var acmeCache *string
+var acmeHTTP *string
+var apiTokens *string
+var basicAuth *string
+var htpasswdPath *string
+var viewers *string
+var viewerTokens *string
+var corsOrigins *string
+var corsMethods *string
+var basePath *string
+var socketPath *string
+var listen *string
+var compression *bool
+var accessLog *bool
+var accessLogFile *string
+var rateLimit *float64
+var rateBurst *int
+var trustedProxies *string
+var pprofEnabled *bool
+var grpcAddress *string
+var ndjson *bool
+var replayPath *string
+var simulated *bool
+var replaySpeed *float64
+
+// whether scanning is stopped, or stopping
+var stop atomic.Bool
+
+// running scan loops
+var scanning sync.WaitGroup
+
+// the HCI device used for connecting and advertising
+var bleDevice *linux.Device
+
+// how long a device stays visible after it was last detected
+var expiryWindow *time.Duration
 
 // Device represents a BLE device
 type Device struct {
-	Address       string    `json:"address"`
-	Detected      time.Time `json:"detected"`
-	Since         string    `json:"since"`
-	Name          string    `json:"name"`
-	RSSI          int       `json:"rssi"`
-	Advertisement string    `json:"advertisement"`
-	ScanResponse  string    `json:"scanresponse"`
+	Address           string              `json:"address"`
+	AddressType       string              `json:"addresstype,omitempty"`
+	PrivateAddress    string              `json:"privateaddress,omitempty"`
+	Group             string              `json:"group,omitempty"`
+	FirstSeen         time.Time           `json:"firstseen"`
+	Detected          time.Time           `json:"detected"`
+	Since             string              `json:"since"`
+	Name              string              `json:"name"`
+	Vendor            string              `json:"vendor,omitempty"`
+	Appearance        string              `json:"appearance,omitempty"`
+	Services          []Service           `json:"services,omitempty"`
+	RSSI              int                 `json:"rssi"`
+	TxPower           *int                `json:"txpower,omitempty"`
+	Connectable       bool                `json:"connectable"`
+	Packets           int                 `json:"packets"`
+	SmoothedRSSI      float64             `json:"smoothedrssi"`
+	Distance          float64             `json:"distance,omitempty"`
+	PacketsLastMinute int                 `json:"packetslastminute"`
+	Interval          float64             `json:"interval,omitempty"`
+	Adapters          map[string]Sighting `json:"adapters,omitempty"`
+	Advertisement     string              `json:"advertisement"`
+	ScanResponse      string              `json:"scanresponse"`
+	AD                *AdvertisingData    `json:"ad,omitempty"`
+	IBeacon           *IBeacon            `json:"ibeacon,omitempty"`
+	Continuity        []string            `json:"continuity,omitempty"`
+	AltBeacon         *AltBeacon          `json:"altbeacon,omitempty"`
+	Eddystone         *Eddystone          `json:"eddystone,omitempty"`
+	Info              *DeviceInfo         `json:"info,omitempty"`
+	Battery           *Battery            `json:"battery,omitempty"`
+	Tracker           *Tracker            `json:"tracker,omitempty"`
+	Sensor            *Sensor             `json:"sensor,omitempty"`
+	Tags              []string            `json:"tags,omitempty"`
+
+	// connectable and advertising the Battery Service
+	batteryService bool
+	// how uncertain the smoothed RSSI is, for the Kalman filter
+	rssiVariance float64
 }
 
 var mutex sync.RWMutex
 var devices map[string]Device
 
 func init() {
+	stop.Store(true)
 	devices = make(map[string]Device)
 	mutex = sync.RWMutex{}
 	d, err := filepath.Abs(filepath.Dir(os.Args[0]))
@@ -50,11 +182,120 @@ func init() {
 	}
 	dir = flag.String("dir", d, "directory where the public directory is in")
 	dur = flag.Duration("d", 5*time.Second, "Scan duration")
-	port = flag.Int("p", 23232, "the port where the server starts")
-	flag.Parse()
+	expiryWindow = flag.Duration("expiry", time.Minute, "how long a device stays visible after it was last seen, before it's forgotten")
+	port = flag.Int("p", 23232, "the port where the server starts, 0 to not listen on TCP")
+	socketPath = flag.String("socket", "", "Unix socket to also listen on, for a local reverse proxy")
+	listen = flag.String("listen", "", "comma-separated address:port pairs to listen on instead of all IPv4 addresses at -p, e.g. 127.0.0.1:23232,[::1]:23232")
+	compression = flag.Bool("compress", true, "compress responses with gzip or deflate for clients that accept them")
+	accessLog = flag.Bool("access-log", true, "log every request to the web server")
+	accessLogFile = flag.String("access-log-file", "", "file to log requests to, instead of blueblue.log")
+	rateLimit = flag.Float64("rate-limit", 10, "requests per second each client can make to /start, /stop, the JSON API and to log in, 0 for no limit")
+	rateBurst = flag.Int("rate-burst", 20, "requests each client can make in a burst above the rate limit")
+	trustedProxies = flag.String("trusted-proxies", "", "comma-separated addresses or networks of reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted, unix for the one on the -socket")
+	simulated = flag.Bool("simulate", false, "simulate beacons, phones and sensors instead of scanning with the adapters")
+	replayPath = flag.String("replay", "", "btsnoop or NDJSON capture to replay instead of scanning with the adapters")
+	replaySpeed = flag.Float64("replay-speed", 1, "how many times faster than recorded to replay, 0 for as fast as possible")
+	ndjson = flag.Bool("ndjson", false, "don't start the web server, scan and write every detection to stdout as a line of JSON")
+	grpcAddress = flag.String("grpc", "", "address:port to serve the gRPC API at, e.g. :23233")
+	pprofEnabled = flag.Bool("pprof", false, "serve profiles for admins at /debug/pprof")
+	hciAdapters = flag.String("hci", defaultAdapter(), "comma-separated list of HCI adapters to scan on, e.g. hci0,hci1, bluez or bluez:hci1 to scan through BlueZ, or simulator, replay or corebluetooth on a Mac")
+	adapterErrors = flag.Int("adapter-errors", 3, "number of consecutive scan errors after which an adapter is reopened")
+	adapterSilence = flag.Duration("adapter-silence", 0, "reopen an HCI adapter that hasn't received any advertisements for this long, 0 to never")
+	activeScan = flag.Bool("active", true, "scan actively, soliciting scan responses from advertisers")
+	scanInterval = flag.Duration("scan-interval", 2500*time.Microsecond, "how often the adapter scans, between 2.5ms and 10.24s")
+	scanWindow = flag.Duration("scan-window", 2500*time.Microsecond, "how long each scan lasts, no longer than the scan interval")
+	whitelist = flag.String("whitelist", "", "comma-separated addresses of the only devices to report, filtered by the adapter")
+	duplicates = flag.Bool("duplicates", false, "report every advertisement instead of one per device per scan")
+	sleep = flag.Duration("sleep", 0, "how long to turn the radio off between scans, to save power")
+	schedule = flag.String("schedule", "", "semicolon-separated cron expressions for when to scan, e.g. \"* 8-17 * * 1-5\"")
+	quietPeriod = flag.String("quiet", "", "daily quiet hours as hh:mm-hh:mm, during which scanning, alerts and MQTT publishing are suppressed")
+	statePath = flag.String("state", "", "file to save the scanning state and settings in, to resume them after a restart")
+	configPath = flag.String("config", "", "JSON configuration file, in the same form as /api/v1/config, reloaded on SIGHUP")
+	tlsCert = flag.String("tls-cert", "", "TLS certificate file, to serve over HTTPS")
+	tlsKey = flag.String("tls-key", "", "TLS private key file, to serve over HTTPS")
+	acmeHost = flag.String("acme-host", "", "comma-separated hostnames to get Let's Encrypt certificates for, to serve over HTTPS")
+	acmeEmail = flag.String("acme-email", "", "contact email for the Let's Encrypt account")
+	acmeCache = flag.String("acme-cache", "certs", "directory to cache Let's Encrypt certificates in")
+	acmeHTTP = flag.String("acme-http", ":80", "address to answer Let's Encrypt HTTP-01 challenges at")
+	apiTokens = flag.String("api-tokens", "", "comma-separated tokens that are required to use the JSON API")
+	basicAuth = flag.String("basic-auth", "", "comma-separated user:password credentials that are required to use the pages and API")
+	htpasswdPath = flag.String("htpasswd", "", "htpasswd file with bcrypt hashed passwords of users who can log in")
+	viewers = flag.String("viewers", "", "comma-separated users who can only see devices, everyone else is an admin")
+	viewerTokens = flag.String("viewer-tokens", "", "comma-separated API tokens that can only see devices")
+	corsOrigins = flag.String("cors-origins", "", "comma-separated origins allowed to call the JSON API from the browser, * for any")
+	corsMethods = flag.String("cors-methods", "GET, POST, PUT, DELETE", "methods allowed in cross-origin JSON API requests")
+	basePath = flag.String("base-path", "", "path prefix to serve everything under, e.g. /ble behind a reverse proxy")
+	historySize = flag.Int("history", 100, "number of advertisements kept per device")
+	connectTimeout = flag.Duration("connect-timeout", 10*time.Second, "timeout when connecting to a device")
+	batteryPoll = flag.Duration("battery-poll", 0, "how often to read the battery level of devices with a Battery Service, 0 to disable")
+	irksPath = flag.String("irks", "", "file of IRKs of your own devices, to resolve their private addresses, one name and IRK in hex per line")
+	rssiFilter = flag.String("rssi-filter", FilterEWMA, "how to smooth the RSSI of each device: none, ewma or kalman")
+	rssiAlpha = flag.Float64("rssi-alpha", 0.3, "weight of the latest RSSI in the moving average, between 0 and 1")
+	pathLoss = flag.Float64("path-loss", 2, "path loss exponent of the environment for estimating distances, 2 in free space and up to 4 indoors")
+	batteryLow = flag.Int("battery-low", 20, "battery level in percent at or below which an alert is raised")
+	batteryAlert = flag.String("battery-alert", "", "command to run with the device address and battery level when the battery is low")
+	trackerTime = flag.Duration("tracker-time", 10*time.Minute, "how long an unknown AirTag, SmartTag or Tile follows the scanner before an alert is raised, 0 to never raise one")
+	trackerAlert = flag.String("tracker-alert", "", "command to run with the address, kind of tracker and minutes it has been following when an unknown tracker is following")
+	webhooks = flag.String("webhooks", "", "comma-separated URLs to post events to as JSON")
+	webhookEvents = flag.String("webhook-events", "new,arrive,depart,tracker", "comma-separated types of events to post to the webhooks")
+	messagesPath = flag.String("messages", "", "file of templates for the messages of notifications, named after the event types, replacing the default ones")
+	telegramToken = flag.String("telegram-token", "", "token of the Telegram bot to send notifications with")
+	telegramChat = flag.String("telegram-chat", "", "ID of the Telegram chat to send notifications to")
+	telegramEvents = flag.String("telegram-events", "arrive,depart,tracker,battery_low", "comma-separated types of events to send to Telegram")
+	slackWebhook = flag.String("slack-webhook", "", "Slack incoming webhook URL to send notifications to")
+	slackEvents = flag.String("slack-events", "unknown,tracker,battery_low", "comma-separated types of events to send to Slack")
+	discordWebhook = flag.String("discord-webhook", "", "Discord webhook URL to send notifications to")
+	discordEvents = flag.String("discord-events", "unknown,tracker,battery_low", "comma-separated types of events to send to Discord")
+	smtpServer = flag.String("smtp", "", "host:port of the SMTP server to send emails through")
+	smtpUser = flag.String("smtp-user", "", "user to log in to the SMTP server as, if it needs logging in")
+	smtpPassword = flag.String("smtp-password", "", "password of the SMTP user")
+	emailTo = flag.String("email-to", "", "comma-separated addresses to send emails to")
+	emailEvents = flag.String("email-events", "tracker,battery_low", "comma-separated types of events to send emails for")
+	emailDigest = flag.String("email-digest", "", "time of day to send a digest of the day's devices by email, as hh:mm")
+	gattServer = flag.Bool("gatt-server", false, "expose the scan results over a GATT service and advertise it")
+	hostname, err = os.Hostname()
+	if err != nil {
+		hostname = "blueblue"
+	}
+	emailFrom = flag.String("email-from", "blueblue@"+hostname, "address emails are sent from")
+	mqttBroker = flag.String("mqtt", "", "MQTT broker URL to publish devices to, e.g. tcp://localhost:1883")
+	mqttTopic = flag.String("mqtt-topic", "blueblue/"+hostname, "MQTT topic prefix")
+	mqttQoS = flag.Int("mqtt-qos", 0, "MQTT QoS level (0, 1 or 2)")
+	mqttClientID = flag.String("mqtt-client-id", "blueblue-"+hostname, "MQTT client ID")
+	mqttHA = flag.Bool("mqtt-ha", false, "publish Home Assistant MQTT discovery payloads")
+	mqttHAPrefix = flag.String("mqtt-ha-prefix", "homeassistant", "Home Assistant MQTT discovery prefix")
+	mqttSensors = flag.Bool("mqtt-sensors", false, "publish each decoded sensor reading to its own MQTT topic")
+	dbPath = flag.String("db", "", "SQLite database file to record detections in")
+	influxURL = flag.String("influx", "", "InfluxDB URL to write samples to, e.g. http://localhost:8086")
+	influxVersion = flag.Int("influx-version", 2, "InfluxDB API version (1 or 2)")
+	influxDB = flag.String("influx-db", "blueblue", "InfluxDB database (v1) or bucket (v2)")
+	influxOrg = flag.String("influx-org", "", "InfluxDB organization (v2)")
+	influxToken = flag.String("influx-token", "", "InfluxDB API token (v2) or user:password (v1)")
+	influxInterval = flag.Duration("influx-interval", 10*time.Second, "how often samples are written to InfluxDB")
+}
+
+// set flags from BLUEBLUE_* environment variables, e.g. BLUEBLUE_MQTT_TOPIC
+// for -mqtt-topic, flags given on the command line take precedence
+func flagsFromEnv() {
+	flag.VisitAll(func(f *flag.Flag) {
+		name := "BLUEBLUE_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		// set through the flag set so it counts as given
+		err := flag.Set(f.Name, value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid value %q for %s: %v\n", value, name, err)
+			os.Exit(2)
+		}
+	})
 }
 
 func main() {
+	// parsed here rather than in init so the tests can run
+	flagsFromEnv()
+	parseCommand(os.Args[1:])
 	f, err := os.OpenFile("blueblue.log",
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -62,101 +303,534 @@ func main() {
 	}
 	defer f.Close()
 	logger = log.New(f, "", log.LstdFlags)
+	if command == "scan" {
+		// show what goes wrong when run by hand
+		logger.SetOutput(io.MultiWriter(f, os.Stderr))
+	}
 
-	d, err := linux.NewDevice()
+	switch command {
+	case "version":
+		printVersion(os.Stdout)
+		return
+	case "export":
+		err = exportDetections(*dbPath, os.Stdout)
+		if err != nil {
+			fail("Can't export detections:", err)
+		}
+		return
+	}
+	c := Config{
+		Scan: ScanSettings{
+			Active:     *activeScan,
+			Interval:   float64(*scanInterval) / float64(time.Millisecond),
+			Window:     float64(*scanWindow) / float64(time.Millisecond),
+			Duplicates: *duplicates,
+			Duration:   dur.Seconds(),
+			Sleep:      sleep.Seconds(),
+		},
+		Smoothing: Smoothing{
+			Filter:           *rssiFilter,
+			Alpha:            *rssiAlpha,
+			ProcessNoise:     0.5,
+			MeasurementNoise: 9,
+		},
+		PathLoss:     *pathLoss,
+		Expiry:       expiryWindow.Seconds(),
+		MQTTTopic:    *mqttTopic,
+		MQTTQoS:      *mqttQoS,
+		BatteryLow:   *batteryLow,
+		TrackerAlert: trackerTime.Seconds(),
+	}
+	if *whitelist != "" {
+		c.Scan.Whitelist = strings.Split(*whitelist, ",")
+	}
+	names := strings.Split(*hciAdapters, ",")
+	crons := []string{}
+	if *schedule != "" {
+		crons = strings.Split(*schedule, ";")
+	}
+	// the state takes precedence over the defaults, and the flags given and
+	// the configuration file over the state
+	state, err := loadState()
+	if err != nil {
+		logger.Fatal("Can't load state:", err)
+	}
+	if state != nil {
+		logger.Println("Resuming from", *statePath)
+		c = resumedConfig(state.Config, c)
+		if len(state.Adapters) > 0 && !flagGiven("hci") {
+			names = state.Adapters
+		}
+		if !flagGiven("schedule") {
+			crons = state.Schedules
+		}
+	}
+	if *configPath != "" {
+		err = readConfig(*configPath, &c)
+		if err != nil {
+			logger.Fatal("Can't read configuration:", err)
+		}
+		err = readUsers(*configPath)
+		if err != nil {
+			logger.Fatal("Can't read users:", err)
+		}
+		logIgnoredSettings(*configPath)
+		go reloadOnHangup()
+	}
+	err = setConfig(c)
+	if err != nil {
+		logger.Fatal("Invalid configuration:", err)
+	}
+	*basePath = strings.TrimSuffix(*basePath, "/")
+	if *historySize < 0 {
+		logger.Fatal("Invalid history size ", *historySize, ", must be 0 or more")
+	}
+	err = openAccessLog()
+	if err != nil {
+		logger.Fatal("Can't open access log:", err)
+	}
+	if *htpasswdPath != "" {
+		err = loadHtpasswd(*htpasswdPath)
+		if err != nil {
+			logger.Fatal("Can't load htpasswd file:", err)
+		}
+	}
+	if *irksPath != "" {
+		err = loadIRKs(*irksPath)
+		if err != nil {
+			logger.Fatal("Can't load IRKs:", err)
+		}
+	}
+	if *quietPeriod != "" {
+		quietHours, err = parseQuietHours(*quietPeriod)
+		if err != nil {
+			logger.Fatal("Invalid quiet hours:", err)
+		}
+	}
+	err = parseTrustedProxies(*trustedProxies)
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies:", err)
+	}
+	switch {
+	case *simulated:
+		names = []string{ScannerSimulator}
+	case *replayPath != "":
+		names = []string{ScannerReplay}
+	}
+	err = openAdapters(names)
 	if err != nil {
 		logger.Fatal("Can't create new device:", err)
 	}
-	ble.SetDefaultDevice(d)
+	if command == "scan" {
+		d, err := scanFor()
+		if err != nil {
+			fail("Can't scan:", err)
+		}
+		err = scanOnce(d, os.Stdout)
+		if err != nil {
+			fail("Can't print devices:", err)
+		}
+		return
+	}
+	if *dbPath != "" {
+		err = openStore(*dbPath)
+		if err != nil {
+			logger.Fatal("Can't open database:", err)
+		}
+	}
+	go expireDevices()
+	for _, cron := range crons {
+		_, err = addSchedule(cron)
+		if err != nil {
+			logger.Fatal("Can't add schedule:", err)
+		}
+	}
+	// there's nothing else to do with the simulator or a replay
+	if (state != nil && state.Scanning) || *simulated || *replayPath != "" {
+		startScanning()
+	}
+	go runSchedules()
+	if *gattServer {
+		err = startPeripheral()
+		if err != nil {
+			logger.Fatal("Can't add GATT service:", err)
+		}
+		err = startAdvertising(Advertising{Type: AdvertiseGATT})
+		if err != nil {
+			logger.Fatal("Can't advertise GATT service:", err)
+		}
+	}
+	if *batteryPoll > 0 {
+		go pollBatteries()
+	}
+	if *influxURL != "" {
+		flushing.Add(1)
+		go writeInflux()
+	}
+	if *mqttBroker != "" {
+		err = startMQTT()
+		if err != nil {
+			logger.Fatal("Can't connect to MQTT broker:", err)
+		}
+	}
+	if *webhooks != "" {
+		startWebhooks()
+	}
+	if *telegramToken != "" {
+		err = startTelegram()
+		if err != nil {
+			logger.Fatal("Can't start Telegram notifications:", err)
+		}
+	}
+	if *slackWebhook != "" {
+		err = startChat("Slack", *slackWebhook, *slackEvents, "text")
+		if err != nil {
+			logger.Fatal("Can't start Slack notifications:", err)
+		}
+	}
+	if *discordWebhook != "" {
+		err = startChat("Discord", *discordWebhook, *discordEvents, "content")
+		if err != nil {
+			logger.Fatal("Can't start Discord notifications:", err)
+		}
+	}
+	if *smtpServer != "" && *emailTo != "" {
+		err = startEmail()
+		if err != nil {
+			logger.Fatal("Can't start email notifications:", err)
+		}
+	}
+	if *grpcAddress != "" {
+		err = startGRPC(*grpcAddress)
+		if err != nil {
+			logger.Fatal("Can't start gRPC server:", err)
+		}
+	}
+	if *ndjson {
+		headless(os.Stdout)
+		return
+	}
 	serve()
 }
 
 // Handle the advertisement scan
-func adScanHandler(a ble.Advertisement) {
+func adScanHandler(adapter *Adapter, a ble.Advertisement) {
+	captureAdvertisement(a)
+	c := currentConfig()
+	if min := c.MinRSSI; min != 0 && a.RSSI() < min {
+		return
+	}
+	if isExposureNotification(a) {
+		mutex.Lock()
+		exposureNotifications[a.Addr().String()] = time.Now()
+		mutex.Unlock()
+		advertisementsTotal.Inc()
+		statAdvertisements.Add(1)
+		return
+	}
 	mutex.Lock()
+	// the user's own devices are tracked by their identity, whatever
+	// private address they're using
+	address, privateAddress := a.Addr().String(), ""
+	if name := resolveAddress(address); name != "" {
+		address, privateAddress = name, address
+	}
+	previous, found := devices[address]
+	now := time.Now()
+	sightings := mergeSightings(previous.Adapters, adapter, Sighting{RSSI: a.RSSI(), Detected: now})
+	firstSeen := previous.FirstSeen
+	if !found {
+		firstSeen = now
+		// a device known from the database keeps its first detection
+		if known, ok := knownDevices[address]; ok {
+			firstSeen = known.FirstSeen
+			delete(knownDevices, address)
+		}
+	}
 	device := Device{
-		Address:       a.Addr().String(),
-		Detected:      time.Now(),
-		Name:          clean(a.LocalName()),
-		RSSI:          a.RSSI(),
-		Advertisement: formatHex(hex.EncodeToString(a.LEAdvertisingReportRaw())),
-		ScanResponse:  formatHex(hex.EncodeToString(a.ScanResponseRaw())),
-	}
-	devices[a.Addr().String()] = device
+		Address:        address,
+		AddressType:    classifyAddress(a, a.Addr().String()),
+		PrivateAddress: privateAddress,
+		FirstSeen:      firstSeen,
+		Detected:       now,
+		Name:           clean(a.LocalName()),
+		Vendor:         lookupVendor(a, a.Addr().String()),
+		RSSI:           strongestRSSI(sightings),
+		Connectable:    a.Connectable(),
+		Adapters:       sightings,
+		Advertisement:  formatHex(hex.EncodeToString(a.LEAdvertisingReportRaw())),
+		ScanResponse:   formatHex(hex.EncodeToString(a.ScanResponseRaw())),
+		AD:             parseAdvertisement(a),
+		IBeacon:        decodeIBeacon(a.ManufacturerData()),
+		Continuity:     continuityMessages(a.ManufacturerData()),
+		AltBeacon:      decodeAltBeacon(a.ManufacturerData()),
+		Eddystone:      decodeEddystone(a.ServiceData(), previous.Eddystone),
+		Sensor:         decodeSensor(a, previous.Sensor),
+		Info:           previous.Info,
+		Battery:        previous.Battery,
+
+		batteryService: advertisesBattery(a),
+	}
+	device.TxPower = txPowerLevel(a, device.AD)
+	if device.AD != nil && device.AD.Appearance != nil {
+		device.Appearance = appearanceName(*device.AD.Appearance)
+	}
+	device.Services = advertisedServices(a, device.AD)
+	c.Smoothing.smooth(&device, previous, found)
+	device.Distance = estimateDistance(device, c)
+	countPacket(&device)
+	correlate(&device, found, previous)
+	followed := followTracker(&device, trackerKind(a), c)
+	arrivals := updatePresence(device, c)
+	unknown := unknownDevice(device, found, c)
+	acting := applyRules(&device, c)
+	devices[address] = device
+	recordHistory(device)
 	mutex.Unlock()
+
+	recordDetection(device)
+	advertisementsTotal.Inc()
+	statAdvertisements.Add(1)
+	deviceRSSI.WithLabelValues(device.Address).Set(float64(device.RSSI))
+
+	if found {
+		broker.Publish(Event{Type: EventUpdate, Device: device})
+	} else {
+		broker.Publish(Event{Type: EventNew, Device: device})
+	}
+	if unknown {
+		broker.Publish(Event{Type: EventUnknown, Device: device})
+	}
+	if followed {
+		alertTracker(device)
+	}
+	for _, e := range arrivals {
+		logger.Println(e.Presence.Name, "arrived")
+		broker.Publish(e)
+	}
+	for _, r := range acting {
+		actOnRule(r, device)
+	}
 }
 
 // start the web server
 func serve() {
 	mux := http.NewServeMux()
 	mux.Handle("/public/", http.StripPrefix("/public/", http.FileServer(http.Dir(*dir+"/public"))))
-	mux.HandleFunc("/", index)
-	mux.HandleFunc("/stop", stopScan)
-	mux.HandleFunc("/start", startScan)
-	mux.HandleFunc("/devices", showDevices)
+	mux.Handle("/", instrument("index", index))
+	mux.Handle("/login", instrument("login", login))
+	mux.Handle("/logout", instrument("logout", logout))
+	mux.Handle("/stop", instrument("stop", stopScan))
+	mux.Handle("/start", instrument("start", startScan))
+	mux.Handle("/devices", instrument("devices", showDevices))
+	mux.Handle("/device", instrument("device", showDevice))
+	mux.Handle("/api/v1/devices", instrument("api_devices", apiDevices))
+	mux.Handle("/api/v1/devices/", instrument("api_device", apiDevice))
+	mux.Handle("/api/v1/devices/export", instrument("api_export", apiExport))
+	mux.Handle("/api/v1/devices/groups", instrument("api_groups", apiGroups))
+	mux.Handle("/api/v1/presence", instrument("api_presence", apiPresence))
+	mux.Handle("/api/v1/adapters", instrument("api_adapters", apiAdapters))
+	mux.Handle("/api/v1/scan/settings", instrument("api_scan_settings", apiScanSettings))
+	mux.Handle("/api/v1/config", instrument("api_config", apiConfig))
+	mux.Handle("/api/v1/version", instrument("api_version", apiVersion))
+	mux.Handle("/api/v1/openapi.json", instrument("api_openapi", apiOpenAPI))
+	mux.Handle("/graphql", instrument("graphql", graphqlHandler))
+	mux.Handle("/api/v1/schedules", instrument("api_schedules", apiSchedules))
+	mux.Handle("/api/v1/schedules/", instrument("api_schedule", apiSchedule))
+	mux.Handle("/status", instrument("status", showStatus))
+	mux.Handle("/api/v1/status", instrument("api_status", showStatus))
+	mux.Handle("/healthz", instrument("healthz", healthz))
+	mux.Handle("/readyz", instrument("readyz", readyz))
+	mux.Handle("/api/v1/advertising", instrument("api_advertising", apiAdvertising))
+	mux.Handle("/api/v1/capture", instrument("api_capture", apiCapture))
+	mux.Handle("/api/v1/capture/download", instrument("api_capture_download", apiCaptureDownload))
+	mux.Handle("/ws", instrument("ws", streamWebSocket))
+	mux.Handle("/events", instrument("events", streamEvents))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/debug/vars", expvar.Handler())
+	if *pprofEnabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
 	server := &http.Server{
-		Addr:    "0.0.0.0:" + strconv.Itoa(*port),
-		Handler: mux,
+		Addr:        "0.0.0.0:" + strconv.Itoa(*port),
+		Handler:     logAccess(withBasePath(compress(cors(limitRate(authenticate(mux)))))),
+		BaseContext: serverContext,
+	}
+	go shutdownOnSignal(server)
+	if *acmeHost != "" {
+		useACME(server)
+	}
+	addresses := []string{}
+	if *listen != "" {
+		addresses = strings.Split(*listen, ",")
+	} else if *port != 0 {
+		addresses = append(addresses, server.Addr)
+	}
+	var wg sync.WaitGroup
+	for _, address := range addresses {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			err := serveTCP(server, address)
+			if err != nil && err != http.ErrServerClosed {
+				logger.Println("Web server failed at", address, err)
+			}
+		}(address)
 	}
-	fmt.Println("Started blueblue server at", server.Addr)
-	server.ListenAndServe()
+	if *socketPath != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := serveSocket(server, *socketPath)
+			if err != nil && err != http.ErrServerClosed {
+				logger.Println("Web server failed on socket:", err)
+			}
+		}()
+	}
+	wg.Wait()
+	logger.Println("Stopped blueblue server.")
+}
+
+// serve on a TCP address, over TLS if there are certificates
+func serveTCP(server *http.Server, address string) error {
+	listener, err := net.Listen("tcp", strings.TrimSpace(address))
+	if err != nil {
+		return err
+	}
+	if *acmeHost != "" {
+		fmt.Println("Started blueblue server with Let's Encrypt at", address)
+		return server.ServeTLS(listener, "", "")
+	}
+	if *tlsCert != "" || *tlsKey != "" {
+		fmt.Println("Started blueblue server with TLS at", address)
+		return server.ServeTLS(listener, *tlsCert, *tlsKey)
+	}
+	fmt.Println("Started blueblue server at", address)
+	return server.Serve(listener)
+}
+
+// serve plain HTTP on a Unix socket, for a local reverse proxy
+func serveSocket(server *http.Server, path string) error {
+	// remove the socket left behind by a crash
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Started blueblue server at", path)
+	return server.Serve(listener)
+}
+
+// parse a template in the public directory, with base giving the base path
+// for links
+func parseTemplate(name string) (*template.Template, error) {
+	funcs := template.FuncMap{"base": func() string { return *basePath }, "ago": ago}
+	return template.New(name).Funcs(funcs).ParseFiles(*dir + "/public/" + name)
+}
+
+// how long ago the time was, to the second
+func ago(t time.Time) string {
+	return time.Since(t).Round(time.Second).String()
+}
+
+// serve everything under the base path, if there is one
+func withBasePath(handler http.Handler) http.Handler {
+	if *basePath == "" {
+		return handler
+	}
+	return http.StripPrefix(*basePath, handler)
 }
 
 // index for web server
 func index(w http.ResponseWriter, r *http.Request) {
-	t, _ := template.ParseFiles(*dir + "/public/index.html")
-	t.Execute(w, stop)
+	t, _ := parseTemplate("index.html")
+	t.Execute(w, stop.Load())
 }
 
 // handler to show list of devices
 func showDevices(w http.ResponseWriter, r *http.Request) {
-	t, _ := template.ParseFiles(*dir + "/public/devices.html")
+	window, err := requestExpiry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, _ := parseTemplate("devices.html")
+	t.Execute(w, withAddressTypes(devicesSeenWithin(window), r.URL.Query().Get("addresstype")))
+}
 
-	// convert map to array, added detect since duration and
-	// remove anything that's more than 60 seconds
+// handler to show the details of a device
+func showDevice(w http.ResponseWriter, r *http.Request) {
+	mutex.RLock()
+	device, ok := devices[r.URL.Query().Get("address")]
+	recentPackets(&device)
+	mutex.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	device.Since = strconv.Itoa(int(time.Since(device.Detected).Seconds()))
+	t, _ := parseTemplate("device.html")
+	t.Execute(w, device)
+}
+
+// the visible devices, sorted by RSSI
+func deviceList() []Device {
+	return devicesSeenWithin(expiry())
+}
+
+// convert map to array, added detect since duration and
+// remove anything that wasn't seen within the window, sorted by RSSI
+func devicesSeenWithin(window time.Duration) []Device {
+	mutex.RLock()
+	defer mutex.RUnlock()
 	data := []Device{}
+	tn := time.Now().Add(-window)
 	for _, device := range devices {
 		device.Since = strconv.Itoa(int(time.Since(device.Detected).Seconds()))
-		tn := time.Now().Add(-1 * time.Duration(60) * time.Second)
+		recentPackets(&device)
 		if tn.Before(device.Detected) {
 			data = append(data, device)
 		}
 	}
 	// sort by RSSI
 	sort.SliceStable(data, func(i, j int) bool {
-		return data[i].RSSI > data[j].RSSI
+		return data[i].SmoothedRSSI > data[j].SmoothedRSSI
 	})
-	t.Execute(w, data)
+	return data
 }
 
 // handler to start scanning
 func startScan(w http.ResponseWriter, r *http.Request) {
-	if !stop {
+	if !startScanning() {
 		w.WriteHeader(409)
-	} else {
-		go scan()
 	}
 }
 
 // handler to stop scanning
 func stopScan(w http.ResponseWriter, r *http.Request) {
-	if stop {
+	if !stopScanning() {
 		w.WriteHeader(409)
-	} else {
-		stop = true
 	}
 }
 
-// scan goroutine
+// scan goroutine, started by runScan
 func scan() {
-	stop = false
-	logger.Println("Started scanning every", *dur)
-	for !stop {
-		ctx := ble.WithSigHandler(context.WithTimeout(context.Background(), *dur))
-		ble.Scan(ctx, false, adScanHandler, nil)
+	defer scanning.Done()
+	settings := currentScanSettings()
+	logger.Println("Started scanning every", settings.scanDuration(), "sleeping", settings.sleepDuration(), "in between")
+	var wg sync.WaitGroup
+	for _, adapter := range adapters {
+		wg.Add(1)
+		go adapter.scan(&wg)
 	}
+	wg.Wait()
 	logger.Println("Stopped scanning.")
-	stop = true
+	// the adapters can also finish by themselves, like at the end of a replay
+	stop.CompareAndSwap(false, true)
 }
 
 // reformat string for proper display of hex
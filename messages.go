@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// the messages notifications are sent with, a template for each event type
+// and a default one for the rest
+const defaultMessages = `
+{{- define "new" }}New device {{ .Device.Address }}{{ with .Device.Name }} {{ . }}{{ end }} at {{ .Device.RSSI }} dBm{{ end }}
+{{- define "unknown" }}Unknown connectable device {{ .Device.Address }}{{ with .Device.Name }} {{ . }}{{ end }}{{ with .Device.Vendor }} by {{ . }}{{ end }} appeared at {{ .Device.RSSI }} dBm{{ end }}
+{{- define "arrive" }}{{ .Presence.Name }} arrived{{ end }}
+{{- define "depart" }}{{ .Presence.Name }} departed{{ end }}
+{{- define "tracker" }}Unknown {{ .Device.Tracker.Kind }} tracker {{ .Device.Address }} has been following for {{ ago .Device.Tracker.Since }}{{ end }}
+{{- define "rule" }}{{ .Device.Address }}{{ with .Device.Name }} {{ . }}{{ end }} matched rule {{ .Rule }} at {{ .Device.RSSI }} dBm{{ end }}
+{{- define "battery_low" }}Low battery on {{ .Device.Address }}{{ with .Device.Name }} {{ . }}{{ end }}: {{ .Device.Battery.Level }}%{{ end }}
+{{- define "default" }}{{ .Type }} {{ .Device.Address }}{{ with .Device.Name }} {{ . }}{{ end }}{{ end }}
+`
+
+// parse the default messages, and then the file of messages if there is
+// one, whose templates replace the defaults of the same name
+func parseMessages(path string) (*template.Template, error) {
+	t, err := template.New("messages").Funcs(template.FuncMap{"ago": ago}).Parse(defaultMessages)
+	if err != nil || path == "" {
+		return t, err
+	}
+	return t.ParseFiles(path)
+}
+
+// the message for the event, from the template named after its type
+func eventMessage(t *template.Template, e Event) (string, error) {
+	name := e.Type
+	if t.Lookup(name) == nil {
+		name = "default"
+	}
+	buf := &bytes.Buffer{}
+	err := t.ExecuteTemplate(buf, name, e)
+	return buf.String(), err
+}
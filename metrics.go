@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	advertisementsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blueblue_advertisements_total",
+		Help: "Number of advertisements received.",
+	})
+	deviceRSSI = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "blueblue_device_rssi_dbm",
+		Help: "Last RSSI of each visible device.",
+	}, []string{"address"})
+	scanRestarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blueblue_scan_restarts_total",
+		Help: "Number of times the scan loop has started a new scan.",
+	})
+	adapterErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "blueblue_adapter_errors_total",
+		Help: "Number of scan errors by adapter.",
+	}, []string{"adapter"})
+	adapterRecoveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "blueblue_adapter_recoveries_total",
+		Help: "Number of times an adapter has been reopened after it stopped working.",
+	}, []string{"adapter"})
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "blueblue_http_requests_total",
+		Help: "Number of HTTP requests by handler, method and status code.",
+	}, []string{"handler", "method", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "blueblue_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by handler.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		advertisementsTotal,
+		deviceRSSI,
+		scanRestarts,
+		adapterErrorsTotal,
+		adapterRecoveriesTotal,
+		httpRequests,
+		httpDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "blueblue_devices_visible",
+			Help: "Number of devices currently visible.",
+		}, func() float64 {
+			return float64(len(deviceList()))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "blueblue_exposure_notifications_visible",
+			Help: "Number of addresses currently advertising Exposure Notifications.",
+		}, func() float64 {
+			return float64(exposureNotificationsSince(time.Now().Add(-expiry())))
+		}),
+	)
+}
+
+// wrap the handler to record request counts and durations
+func instrument(name string, handler http.HandlerFunc) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return timeHandler(name, promhttp.InstrumentHandlerDuration(httpDuration.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(httpRequests.MustCurryWith(labels), handler)))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// connect to the MQTT broker and start publishing detected devices
+func startMQTT() error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(*mqttBroker).
+		SetClientID(*mqttClientID).
+		SetAutoReconnect(true)
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	logger.Println("Connected to MQTT broker at", *mqttBroker)
+	mqttClient = client
+	go publishMQTT(client)
+	return nil
+}
+
+// publish every detected device as a JSON message under the topic prefix
+func publishMQTT(client mqtt.Client) {
+	ch := broker.Subscribe()
+	defer broker.Unsubscribe(ch)
+	for e := range ch {
+		if hushed(e) {
+			continue
+		}
+		if e.Presence != nil {
+			publishPresence(client, *e.Presence)
+			continue
+		}
+		if *mqttHA {
+			publishHomeAssistant(client, e)
+		}
+		if e.Type == EventExpire {
+			continue
+		}
+		payload, err := json.Marshal(e.Device)
+		if err != nil {
+			logger.Println("Cannot encode device for MQTT:", err)
+			continue
+		}
+		c := currentConfig()
+		client.Publish(c.MQTTTopic+"/devices/"+e.Device.Address, byte(c.MQTTQoS), false, payload)
+		if *mqttSensors && e.Device.Sensor != nil {
+			publishSensor(client, e.Device)
+		}
+	}
+}
+
+// check if the event isn't published during quiet hours. Devices that are
+// gone and known devices that departed still are, so their retained state
+// isn't left home until quiet hours are over
+func hushed(e Event) bool {
+	if !quiet() || e.Type == EventExpire {
+		return false
+	}
+	return e.Presence == nil || e.Presence.Present
+}
+
+// publish whether the known device is present, home or not_home, retained
+// under <topic>/presence/<name>
+func publishPresence(client mqtt.Client, presence Presence) {
+	c := currentConfig()
+	state := "not_home"
+	if presence.Present {
+		state = "home"
+	}
+	client.Publish(c.MQTTTopic+"/presence/"+presence.Name, byte(c.MQTTQoS), true, state)
+}
+
+// publish each of the device's sensor readings as a plain number under
+// <topic>/devices/<address>/sensor/<reading>
+func publishSensor(client mqtt.Client, device Device) {
+	c := currentConfig()
+	topic := c.MQTTTopic + "/devices/" + device.Address + "/sensor/"
+	for name, value := range device.Sensor.readings() {
+		client.Publish(topic+name, byte(c.MQTTQoS), false, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+}
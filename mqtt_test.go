@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestHushedDuringQuietHours(t *testing.T) {
+	defer func() { quietHours = nil }()
+	tests := []struct {
+		e    Event
+		want bool
+	}{
+		{Event{Type: EventNew}, true},
+		{Event{Type: EventUpdate}, true},
+		{Event{Type: EventExpire}, false},
+		{Event{Type: EventArrive, Presence: &Presence{Present: true}}, true},
+		{Event{Type: EventDepart, Presence: &Presence{Present: false}}, false},
+	}
+	for _, test := range tests {
+		quietHours = nil
+		if hushed(test.e) {
+			t.Errorf("%s hushed outside quiet hours", test.e.Type)
+		}
+		quietHours = &QuietHours{start: 0, end: 24 * 60}
+		if got := hushed(test.e); got != test.want {
+			t.Errorf("%s hushed = %v during quiet hours, want %v", test.e.Type, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// scan without the web server, writing every detection as a line of JSON,
+// until blueblue is shut down
+func headless(w io.Writer) {
+	ch := broker.Subscribe()
+	flushing.Add(1)
+	go writeNDJSON(ch, w)
+	startScanning()
+	shutdownOnSignal(nil)
+}
+
+// write the devices of new and update events as they come, one JSON object
+// per line
+func writeNDJSON(ch chan Event, w io.Writer) {
+	defer flushing.Done()
+	defer broker.Unsubscribe(ch)
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type != EventNew && e.Type != EventUpdate {
+				continue
+			}
+			err := encoder.Encode(e.Device)
+			if err != nil {
+				logger.Println("Cannot write detection:", err)
+			}
+		case <-shuttingDown:
+			return
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// an OpenAPI document, schema or operation
+type object map[string]interface{}
+
+// schemas collects the schemas of the types in the document, by name
+type schemas map[string]interface{}
+
+// the schema of a Go type, generated from its fields and JSON tags, adding
+// named structs to the components so they are only described once
+func (s schemas) of(t reflect.Type) object {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return object{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return object{"type": "integer", "description": "nanoseconds"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return object{"type": "string", "format": "byte"}
+		}
+		return object{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if _, ok := s[t.Name()]; !ok && t.Name() != "" {
+			s[t.Name()] = object{} // placeholder, in case the type refers to itself
+			s[t.Name()] = s.properties(t)
+		}
+		if t.Name() == "" {
+			return s.properties(t)
+		}
+		return object{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return object{}
+}
+
+// the object schema of a struct's exported JSON fields
+func (s schemas) properties(t reflect.Type) object {
+	properties := object{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		properties[tag] = s.of(f.Type)
+	}
+	return object{"type": "object", "properties": properties}
+}
+
+// an operation, with the type of its JSON request body and response, which
+// are nil if there's none
+func (s schemas) operation(summary string, request, response interface{}) object {
+	op := object{"summary": summary}
+	if request != nil {
+		op["requestBody"] = object{"content": object{
+			"application/json": object{"schema": s.of(reflect.TypeOf(request))},
+		}}
+	}
+	if response != nil {
+		op["responses"] = object{"200": object{
+			"description": "OK",
+			"content": object{
+				"application/json": object{"schema": s.of(reflect.TypeOf(response))},
+			},
+		}}
+	} else {
+		op["responses"] = object{"204": object{"description": "No Content"}}
+	}
+	return op
+}
+
+// the parameters of a device path
+var addressParameter = object{"name": "address", "in": "path", "required": true, "schema": object{"type": "string"}}
+var characteristicParameters = []object{
+	addressParameter,
+	{"name": "service", "in": "path", "required": true, "schema": object{"type": "string"}},
+	{"name": "characteristic", "in": "path", "required": true, "schema": object{"type": "string"}},
+}
+
+// the OpenAPI 3 document describing the JSON API
+func openAPI() object {
+	s := schemas{}
+	paths := object{
+		"/devices": object{
+			"parameters": []object{
+				{"name": "expiry", "in": "query", "schema": object{"type": "number"}},
+				{"name": "addresstype", "in": "query", "schema": object{"type": "string"}},
+			},
+			"get": s.operation("List the devices seen within the expiry window, in seconds, with the comma-separated address types, strongest first", nil, []Device{}),
+		},
+		"/devices/export": object{
+			"get": object{
+				"summary": "Export the visible devices, or the advertisements in their histories, as CSV, or a snapshot of all known devices as JSON",
+				"parameters": []object{
+					{"name": "format", "in": "query", "schema": object{"type": "string", "enum": []string{"csv", "json"}}},
+					{"name": "history", "in": "query", "schema": object{"type": "boolean"}},
+				},
+				"responses": object{"200": object{
+					"description": "OK",
+					"content": object{
+						"text/csv":         object{"schema": object{"type": "string"}},
+						"application/json": object{"schema": s.of(reflect.TypeOf(Snapshot{}))},
+					},
+				}},
+			},
+		},
+		"/devices/groups": object{
+			"get": s.operation("List the visible groups of private addresses that are likely the same device", nil, []Group{}),
+		},
+		"/presence": object{
+			"get": s.operation("List whether the known devices are present", nil, []Presence{}),
+		},
+		"/devices/{address}/history": object{
+			"parameters": []object{addressParameter},
+			"get":        s.operation("Get the recent advertisements of a device", nil, []Sample{}),
+		},
+		"/devices/{address}/connect": object{
+			"parameters": []object{addressParameter},
+			"post":       s.operation("Connect to a device over GATT", nil, Connection{}),
+			"delete":     s.operation("Disconnect from a device", nil, nil),
+		},
+		"/devices/{address}/pair": object{
+			"parameters": []object{addressParameter},
+			"post":       s.operation("Pair with a device, which isn't supported yet", nil, nil),
+		},
+		"/devices/{address}/identify": object{
+			"parameters": []object{addressParameter},
+			"post":       s.operation("Read the Device Information Service of a device", nil, DeviceInfo{}),
+		},
+		"/devices/{address}/services": object{
+			"parameters": []object{addressParameter},
+			"get":        s.operation("Discover the GATT services of a device", nil, []GATTService{}),
+		},
+		"/devices/{address}/services/{service}/characteristics/{characteristic}": object{
+			"parameters": characteristicParameters,
+			"get":        s.operation("Read a characteristic", nil, Value{}),
+			"put":        s.operation("Write a characteristic", WriteRequest{}, nil),
+		},
+		"/devices/{address}/services/{service}/characteristics/{characteristic}/notifications": object{
+			"parameters": characteristicParameters,
+			"get":        s.operation("Stream the notifications of a characteristic over WebSocket or server-sent events", nil, Value{}),
+		},
+		"/adapters": object{
+			"get": s.operation("List the adapters in use and available", nil, Adapters{}),
+			"put": s.operation("Switch to other adapters", AdaptersRequest{}, Adapters{}),
+		},
+		"/scan/settings": object{
+			"get": s.operation("Get the scan settings", nil, ScanSettings{}),
+			"put": s.operation("Change the scan settings", ScanSettings{}, ScanSettings{}),
+		},
+		"/config": object{
+			"get": s.operation("Get the configuration", nil, Config{}),
+			"put": s.operation("Change the configuration", Config{}, Config{}),
+		},
+		"/schedules": object{
+			"get":  s.operation("List the scan schedules", nil, []Schedule{}),
+			"post": s.operation("Add a scan schedule", Schedule{}, Schedule{}),
+		},
+		"/schedules/{id}": object{
+			"parameters": []object{{"name": "id", "in": "path", "required": true, "schema": object{"type": "integer"}}},
+			"delete":     s.operation("Remove a scan schedule", nil, nil),
+		},
+		"/advertising": object{
+			"get":    s.operation("Get what is being advertised", nil, Advertising{}),
+			"post":   s.operation("Start advertising", Advertising{}, Advertising{}),
+			"delete": s.operation("Stop advertising", nil, nil),
+		},
+		"/capture": object{
+			"get":    s.operation("Get the state of the capture", nil, Capture{}),
+			"post":   s.operation("Start capturing raw advertising reports, replacing the last capture", nil, Capture{}),
+			"delete": s.operation("Stop capturing", nil, Capture{}),
+		},
+		"/capture/download": object{
+			"get": object{
+				"summary": "Download the capture as a btsnoop file",
+				"responses": object{"200": object{
+					"description": "OK",
+					"content":     object{"application/octet-stream": object{"schema": object{"type": "string", "format": "binary"}}},
+				}},
+			},
+		},
+		"/status": object{
+			"get": s.operation("Get the state of the scanner and its adapters", nil, Status{}),
+		},
+		"/version": object{
+			"get": s.operation("Get the version of the running build", nil, Version{}),
+		},
+	}
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   "blueblue",
+			"version": version,
+		},
+		"servers": []object{{"url": *basePath + "/api/v1"}},
+		"paths":   paths,
+		"components": object{
+			"schemas": s,
+			"securitySchemes": object{
+				"token": object{"type": "http", "scheme": "bearer"},
+				"basic": object{"type": "http", "scheme": "basic"},
+			},
+		},
+		"security": []object{{"token": []string{}}, {"basic": []string{}}},
+	}
+}
+
+// handler to serve the OpenAPI document
+func apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPI())
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// the number of gaps between advertisements kept to estimate the advertising
+// interval from
+const intervalGaps = 32
+
+// advertisements closer together than this are taken to be the same
+// advertising event, such as a scan response or another adapter hearing it
+const sameEvent = 10 * time.Millisecond
+
+// packetCounter counts the advertisements received from a device, in total
+// and in each of the last 60 seconds, and keeps the gaps between them
+type packetCounter struct {
+	total   int
+	buckets [60]int
+	// the second each bucket counts
+	seconds [60]int64
+	last    time.Time
+	gaps    []time.Duration
+}
+
+// packet counters of each device, protected by the device mutex
+var packetCounters = make(map[string]*packetCounter)
+
+// count an advertisement received at the time
+func (c *packetCounter) add(t time.Time) {
+	second := t.Unix()
+	i := second % 60
+	if c.seconds[i] != second {
+		c.seconds[i] = second
+		c.buckets[i] = 0
+	}
+	c.buckets[i]++
+	c.total++
+	if gap := t.Sub(c.last); c.last.IsZero() || gap >= sameEvent {
+		if !c.last.IsZero() {
+			c.gaps = append(c.gaps, gap)
+			if len(c.gaps) > intervalGaps {
+				c.gaps = c.gaps[1:]
+			}
+		}
+		c.last = t
+	}
+}
+
+// estimate the advertising interval from the gaps between advertisements.
+// Advertisements that were missed make gaps of several intervals, so only
+// the gaps within half an interval of the shortest are averaged, which
+// evens out the random delay added to each advertising event
+func (c *packetCounter) interval() time.Duration {
+	if len(c.gaps) < 3 {
+		return 0
+	}
+	gaps := append([]time.Duration{}, c.gaps...)
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	var sum time.Duration
+	n := 0
+	for _, gap := range gaps {
+		if gap > gaps[0]*3/2 {
+			break
+		}
+		sum += gap
+		n++
+	}
+	return sum / time.Duration(n)
+}
+
+// the number of advertisements received in the minute up to the time
+func (c *packetCounter) lastMinute(t time.Time) int {
+	count := 0
+	now := t.Unix()
+	for i, second := range c.seconds {
+		if second > now-60 && second <= now {
+			count += c.buckets[i]
+		}
+	}
+	return count
+}
+
+// count the device's latest advertisement, must be called with the device
+// mutex held
+func countPacket(device *Device) {
+	counter, ok := packetCounters[device.Address]
+	if !ok {
+		counter = &packetCounter{}
+		packetCounters[device.Address] = counter
+	}
+	counter.add(device.Detected)
+	device.Packets = counter.total
+	device.PacketsLastMinute = counter.lastMinute(device.Detected)
+	device.Interval = float64(counter.interval()) / float64(time.Millisecond)
+}
+
+// update the device's count of advertisements in the last minute, which
+// drops while it's not heard, must be called with the device mutex held
+func recentPackets(device *Device) {
+	if counter, ok := packetCounters[device.Address]; ok {
+		device.PacketsLastMinute = counter.lastMinute(time.Now())
+	}
+}
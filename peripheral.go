@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// UUIDs of the blueblue GATT service and its characteristics
+var (
+	scanServiceUUID = ble.MustParse("7a1f0001-3c52-4b8d-9f2e-5d6b8b1e0b1e")
+	deviceCountUUID = ble.MustParse("7a1f0002-3c52-4b8d-9f2e-5d6b8b1e0b1e")
+	scanResultsUUID = ble.MustParse("7a1f0003-3c52-4b8d-9f2e-5d6b8b1e0b1e")
+)
+
+// Characteristic User Description descriptor
+var userDescriptionUUID = ble.UUID16(0x2901)
+
+// maximum length of a characteristic value
+const maxValueLength = 512
+
+// if the GATT service has been added to the device
+var peripheral bool
+
+// ScanResult is a visible device in the scan results characteristic, kept
+// short to fit as many devices as possible
+type ScanResult struct {
+	Address string `json:"a"`
+	Name    string `json:"n,omitempty"`
+	RSSI    int    `json:"r"`
+}
+
+// add the GATT service exposing the scan results to the device
+func startPeripheral() error {
+	if bleDevice == nil {
+		return errNoHCI
+	}
+	service := ble.NewService(scanServiceUUID)
+
+	count := service.NewCharacteristic(deviceCountUUID)
+	count.NewDescriptor(userDescriptionUUID).SetValue([]byte("Visible devices"))
+	count.HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.Write(deviceCount())
+	}))
+	count.HandleNotify(ble.NotifyHandlerFunc(notifyDeviceCount))
+
+	results := service.NewCharacteristic(scanResultsUUID)
+	results.NewDescriptor(userDescriptionUUID).SetValue([]byte("Scan results"))
+	results.HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		data := scanResults()
+		if req.Offset() > len(data) {
+			rsp.SetStatus(ble.ErrInvalidOffset)
+			return
+		}
+		rsp.Write(data[req.Offset():])
+	}))
+
+	err := ble.AddService(service)
+	if err != nil {
+		return err
+	}
+	peripheral = true
+	return nil
+}
+
+// the number of visible devices as a little-endian uint16
+func deviceCount() []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(len(deviceList())))
+	return b
+}
+
+// notify the central whenever the number of visible devices changes
+func notifyDeviceCount(req ble.Request, n ble.Notifier) {
+	last := []byte{}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.Context().Done():
+			return
+		case <-ticker.C:
+			count := deviceCount()
+			if string(count) == string(last) {
+				continue
+			}
+			_, err := n.Write(count)
+			if err != nil {
+				return
+			}
+			last = count
+		}
+	}
+}
+
+// the visible devices with the strongest signal, as JSON that fits in a
+// characteristic value
+func scanResults() []byte {
+	results := []ScanResult{}
+	data := []byte("[]")
+	for _, device := range deviceList() {
+		results = append(results, ScanResult{
+			Address: device.Address,
+			Name:    device.Name,
+			RSSI:    device.RSSI,
+		})
+		b, err := json.Marshal(results)
+		if err != nil || len(b) > maxValueLength {
+			break
+		}
+		data = b
+	}
+	return data
+}
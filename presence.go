@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// presence event types
+const (
+	EventArrive = "arrive"
+	EventDepart = "depart"
+)
+
+// EventUnknown is published when a connectable device that isn't known
+// appears
+const EventUnknown = "unknown"
+
+// PresenceDevice is a known device whose presence is tracked
+type PresenceDevice struct {
+	Name string `json:"name"`
+	// the address, or the name of the identity of a device resolved with its
+	// IRK
+	Address string `json:"address"`
+	// the smoothed RSSI the device must be seen with to arrive, and to stay,
+	// 0 for any. Staying takes a weaker signal than arriving so a device at
+	// the edge of range doesn't keep arriving and departing
+	ArriveRSSI int `json:"arriverssi"`
+	StayRSSI   int `json:"stayrssi"`
+	// how long the device must not be seen with the RSSI to stay before it
+	// departs, in seconds, 0 for the expiry
+	Timeout float64 `json:"timeout"`
+}
+
+// check the known device is usable
+func (p PresenceDevice) validate() error {
+	if p.Name == "" || p.Address == "" {
+		return fmt.Errorf("invalid presence device %q at %q, must have a name and address", p.Name, p.Address)
+	}
+	if p.ArriveRSSI > 0 || p.StayRSSI > 0 {
+		return fmt.Errorf("invalid RSSI for %s, must be 0 or less", p.Name)
+	}
+	if p.ArriveRSSI != 0 && p.StayRSSI > p.ArriveRSSI {
+		return fmt.Errorf("invalid RSSI to stay for %s, must be no more than the RSSI to arrive", p.Name)
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("invalid presence timeout %gs for %s, must be 0s or more", p.Timeout, p.Name)
+	}
+	return nil
+}
+
+// Presence is whether a known device is present
+type Presence struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Present bool   `json:"present"`
+	// when it arrived or departed, zero if it hasn't yet
+	Since    time.Time `json:"since"`
+	LastSeen time.Time `json:"lastseen"`
+}
+
+// the presence of the known devices by name, protected by the device mutex
+var presences = make(map[string]*Presence)
+
+// the presence of the known device, which starts out absent
+func presenceOf(p PresenceDevice) *Presence {
+	presence, ok := presences[p.Name]
+	if !ok || presence.Address != p.Address {
+		presence = &Presence{Name: p.Name, Address: p.Address}
+		presences[p.Name] = presence
+	}
+	return presence
+}
+
+// update the presence of the known devices the device is, returns the arrive
+// events to publish. Must be called with the device mutex held
+func updatePresence(device Device, c Config) []Event {
+	events := []Event{}
+	rssi := int(math.Round(device.SmoothedRSSI))
+	for _, p := range c.Presence {
+		if !strings.EqualFold(p.Address, device.Address) {
+			continue
+		}
+		presence := presenceOf(p)
+		if presence.Present {
+			if p.StayRSSI == 0 || rssi >= p.StayRSSI {
+				presence.LastSeen = device.Detected
+			}
+			continue
+		}
+		if p.ArriveRSSI == 0 || rssi >= p.ArriveRSSI {
+			presence.Present, presence.Since, presence.LastSeen = true, device.Detected, device.Detected
+			arrived := *presence
+			events = append(events, Event{Type: EventArrive, Device: device, Presence: &arrived})
+		}
+	}
+	return events
+}
+
+// whether the device is a connectable one that has just appeared and isn't
+// known, neither resolved with an IRK nor tracked for presence. Private
+// addresses that are likely the same device as an earlier one don't count
+// as appearing
+func unknownDevice(device Device, found bool, c Config) bool {
+	if found || !device.Connectable || device.PrivateAddress != "" ||
+		device.Group != "" && device.Group != device.Address {
+		return false
+	}
+	for _, p := range c.Presence {
+		if strings.EqualFold(p.Address, device.Address) {
+			return false
+		}
+	}
+	return true
+}
+
+// depart the known devices that haven't been seen for their timeout and
+// forget the ones that are no longer known, returns the depart events to
+// publish. Must be called with the device mutex held
+func checkPresence(now time.Time, c Config) []Event {
+	events := []Event{}
+	known := map[string]bool{}
+	for _, p := range c.Presence {
+		known[p.Name] = true
+		presence := presenceOf(p)
+		timeout := time.Duration(p.Timeout * float64(time.Second))
+		if timeout == 0 {
+			timeout = time.Duration(c.Expiry * float64(time.Second))
+		}
+		if !presence.Present || now.Sub(presence.LastSeen) <= timeout {
+			continue
+		}
+		presence.Present, presence.Since = false, now
+		device, ok := devices[p.Address]
+		if !ok {
+			device, ok = devices[strings.ToLower(p.Address)]
+		}
+		if !ok {
+			device = Device{Address: p.Address, Name: p.Name}
+		}
+		departed := *presence
+		events = append(events, Event{Type: EventDepart, Device: device, Presence: &departed})
+	}
+	for name := range presences {
+		if !known[name] {
+			delete(presences, name)
+		}
+	}
+	return events
+}
+
+// handler to list the presence of the known devices
+func apiPresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	c := currentConfig()
+	list := []Presence{}
+	mutex.Lock()
+	for _, p := range c.Presence {
+		list = append(list, *presenceOf(p))
+	}
+	mutex.Unlock()
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietHours is a daily period during which scanning, alerts and MQTT
+// publishing are suppressed, e.g. 22:00 to 07:00
+type QuietHours struct {
+	start, end int // minutes since midnight
+}
+
+var quietHours *QuietHours
+
+// parse quiet hours given as hh:mm-hh:mm
+func parseQuietHours(s string) (*QuietHours, error) {
+	var h1, m1, h2, m2 int
+	_, err := fmt.Sscanf(s, "%d:%d-%d:%d", &h1, &m1, &h2, &m2)
+	if err != nil || h1 > 23 || h2 > 23 || m1 > 59 || m2 > 59 || h1 < 0 || h2 < 0 || m1 < 0 || m2 < 0 {
+		return nil, fmt.Errorf("invalid quiet hours %q, must be hh:mm-hh:mm", s)
+	}
+	return &QuietHours{start: h1*60 + m1, end: h2*60 + m2}, nil
+}
+
+// check if it's quiet hours at the given time, the quiet hours can span
+// midnight
+func (q *QuietHours) contains(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	if q.start <= q.end {
+		return now >= q.start && now < q.end
+	}
+	return now >= q.start || now < q.end
+}
+
+// check if it's quiet hours now
+func quiet() bool {
+	return quietHours != nil && quietHours.contains(time.Now())
+}
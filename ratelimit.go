@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// a client's rate limiter and when it was last used
+type clientLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+var limiters = map[string]*clientLimiter{}
+var limitersMutex sync.Mutex
+
+// the networks of the reverse proxies trusted to say who their clients are,
+// and whether the one on the Unix socket is
+var proxyNetworks []*net.IPNet
+var proxySocket bool
+
+// parse the comma-separated addresses and networks of the trusted reverse
+// proxies, or unix for the one on the Unix socket
+func parseTrustedProxies(list string) error {
+	for _, proxy := range strings.Split(list, ",") {
+		proxy = strings.TrimSpace(proxy)
+		switch {
+		case proxy == "":
+		case proxy == "unix":
+			proxySocket = true
+		case strings.Contains(proxy, "/"):
+			_, network, err := net.ParseCIDR(proxy)
+			if err != nil {
+				return err
+			}
+			proxyNetworks = append(proxyNetworks, network)
+		default:
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid proxy address %q", proxy)
+			}
+			proxyNetworks = append(proxyNetworks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		}
+	}
+	return nil
+}
+
+// check if the address is of a trusted reverse proxy, requests over the Unix
+// socket have no IP address
+func trustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return proxySocket
+	}
+	for _, network := range proxyNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// the IP address of the client, as the reverse proxy the request came
+// through says in X-Forwarded-For or X-Real-IP if it's trusted. Only the
+// last address in X-Forwarded-For is the proxy's, the client can make up
+// those before it
+func clientAddress(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trustedProxy(ip) {
+		return ip
+	}
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+		return strings.TrimSpace(addresses[len(addresses)-1])
+	}
+	if real := r.Header.Get("X-Real-IP"); real != "" {
+		return strings.TrimSpace(real)
+	}
+	return ip
+}
+
+// the rate limiter for the client's IP address
+func limiterFor(r *http.Request) *rate.Limiter {
+	ip := clientAddress(r)
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+	cl, ok := limiters[ip]
+	if !ok {
+		cl = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(*rateLimit), *rateBurst)}
+		limiters[ip] = cl
+	}
+	cl.seen = time.Now()
+	return cl.limiter
+}
+
+// forget clients that haven't made requests for a while, until blueblue is
+// shut down
+func forgetLimiters() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			limitersMutex.Lock()
+			for ip, cl := range limiters {
+				if time.Since(cl.seen) > 3*time.Minute {
+					delete(limiters, ip)
+				}
+			}
+			limitersMutex.Unlock()
+		case <-shuttingDown:
+			return
+		}
+	}
+}
+
+// limit how often each client can start and stop scanning, use the JSON API
+// and try to log in, so a runaway client can't thrash the scan loop or the
+// adapter, and passwords can't be guessed quickly
+func limitRate(handler http.Handler) http.Handler {
+	if *rateLimit <= 0 {
+		return handler
+	}
+	go forgetLimiters()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limited := r.URL.Path == "/start" || r.URL.Path == "/stop" || r.URL.Path == "/graphql" ||
+			strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/login" && r.Method == http.MethodPost
+		if limited && !limiterFor(r).Allow() {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusTooManyRequests, errors.New("too many requests"))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAddress(t *testing.T) {
+	defer func() { proxyNetworks, proxySocket = nil, false }()
+	err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1,unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		remote, forwarded, real, want string
+	}{
+		{"203.0.113.5:1234", "198.51.100.7", "", "203.0.113.5"},
+		{"10.1.2.3:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"10.1.2.3:1234", "1.2.3.4, 198.51.100.7", "", "198.51.100.7"},
+		{"192.168.1.1:1234", "", "198.51.100.8", "198.51.100.8"},
+		{"192.168.1.2:1234", "", "198.51.100.8", "192.168.1.2"},
+		{"10.1.2.3:1234", "", "", "10.1.2.3"},
+		{"@", "198.51.100.9", "", "198.51.100.9"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/devices", nil)
+		r.RemoteAddr = test.remote
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if test.real != "" {
+			r.Header.Set("X-Real-IP", test.real)
+		}
+		if got := clientAddress(r); got != test.want {
+			t.Errorf("client of %s forwarded for %q, real IP %q is %s, want %s", test.remote, test.forwarded, test.real, got, test.want)
+		}
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	defer func() { proxyNetworks, proxySocket = nil, false }()
+	for _, list := range []string{"10.0.0.0/33", "proxy.example.com"} {
+		if err := parseTrustedProxies(list); err == nil {
+			t.Errorf("%q parsed, want an error", list)
+		}
+	}
+}
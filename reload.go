@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// read the configuration file over the given configuration, so only the
+// settings in the file are changed
+func readConfig(path string, c *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(c)
+}
+
+// the settings in the configuration file that aren't part of the
+// configuration, like the outputs such as MQTT and InfluxDB, which are set up
+// from their flags at startup and can only be changed with a restart
+func ignoredSettings(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &settings)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{"users": true}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		known[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+	ignored := []string{}
+	for name := range settings {
+		if !known[name] {
+			ignored = append(ignored, name)
+		}
+	}
+	sort.Strings(ignored)
+	return ignored, nil
+}
+
+// log the settings in the configuration file that are ignored
+func logIgnoredSettings(path string) {
+	ignored, err := ignoredSettings(path)
+	if err != nil {
+		logger.Println("Cannot read configuration:", err)
+		return
+	}
+	for _, name := range ignored {
+		logger.Println("Ignoring", name, "in", path+", it can only be changed with its flag and a restart")
+	}
+}
+
+// reload the configuration file whenever blueblue gets a SIGHUP. All the
+// settings of the configuration take effect without restarting, the scan
+// settings from the next scan. The outputs are set up at startup and aren't
+// reloaded
+func reloadOnHangup() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		logger.Println("Reloading", *configPath)
+		c := currentConfig()
+		err := readConfig(*configPath, &c)
+		if err != nil {
+			logger.Println("Cannot read configuration:", err)
+			continue
+		}
+		err = setConfig(c)
+		if err != nil {
+			logger.Println("Cannot apply configuration:", err)
+			continue
+		}
+		err = readUsers(*configPath)
+		if err != nil {
+			logger.Println("Cannot read users:", err)
+		}
+		logIgnoredSettings(*configPath)
+		saveState()
+	}
+}
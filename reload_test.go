@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoredSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blueblue.json")
+	err := os.WriteFile(path, []byte(`{"expiry": 60, "scan": {}, "users": {}, "mqtt": "tcp://localhost:1883", "influx": ""}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignored, err := ignoredSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ignored) != 2 || ignored[0] != "influx" || ignored[1] != "mqtt" {
+		t.Errorf("got %v, want influx and mqtt ignored", ignored)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sausheong/ble"
+	"github.com/sausheong/ble/linux/adv"
+	"github.com/sausheong/ble/linux/hci"
+	"github.com/sausheong/ble/linux/hci/evt"
+)
+
+// a replayed advertisement, made from the raw HCI advertising reports of the
+// advertisement and its scan response, if any
+type replayedAdvertisement struct {
+	e  evt.LEAdvertisingReport
+	sr evt.LEAdvertisingReport
+}
+
+func (a *replayedAdvertisement) packet() *adv.Packet {
+	return adv.NewRawPacket(a.Data(), a.ScanResponse())
+}
+
+func (a *replayedAdvertisement) LocalName() string {
+	return a.packet().LocalName()
+}
+
+func (a *replayedAdvertisement) ManufacturerData() []byte {
+	return a.packet().ManufacturerData()
+}
+
+func (a *replayedAdvertisement) ServiceData() []ble.ServiceData {
+	return a.packet().ServiceData()
+}
+
+func (a *replayedAdvertisement) Services() []ble.UUID {
+	return a.packet().UUIDs()
+}
+
+func (a *replayedAdvertisement) OverflowService() []ble.UUID {
+	return a.packet().UUIDs()
+}
+
+func (a *replayedAdvertisement) SolicitedService() []ble.UUID {
+	return a.packet().ServiceSol()
+}
+
+func (a *replayedAdvertisement) RSSI() int {
+	return int(a.e.RSSI(0))
+}
+
+func (a *replayedAdvertisement) AddressType() uint8 {
+	return a.e.AddressType(0)
+}
+
+func (a *replayedAdvertisement) Data() []byte {
+	return a.e.Data(0)
+}
+
+func (a *replayedAdvertisement) LEAdvertisingReportRaw() []byte {
+	return a.e
+}
+
+func (a *replayedAdvertisement) TxPowerLevel() int {
+	power, _ := a.packet().TxPower()
+	return power
+}
+
+// connectable if it's an ADV_IND or ADV_DIRECT_IND
+func (a *replayedAdvertisement) Connectable() bool {
+	return a.e.EventType(0) == 0x00 || a.e.EventType(0) == 0x01
+}
+
+func (a *replayedAdvertisement) Addr() ble.Addr {
+	b := a.e.Address(0)
+	addr := net.HardwareAddr{b[5], b[4], b[3], b[2], b[1], b[0]}
+	if a.AddressType() == addressRandom {
+		return hci.RandomAddress{Addr: addr}
+	}
+	return addr
+}
+
+func (a *replayedAdvertisement) ScanResponse() []byte {
+	if a.sr == nil {
+		return nil
+	}
+	return a.sr.Data(0)
+}
+
+func (a *replayedAdvertisement) ScanResponseRaw() []byte {
+	if a.sr == nil {
+		return nil
+	}
+	return a.sr
+}
+
+// check that the LE advertising report is complete, so it can be read
+// without going out of bounds
+func validReport(b []byte) bool {
+	if len(b) < 2 || b[0] != evt.LEAdvertisingReportSubCode || b[1] == 0 {
+		return false
+	}
+	n := int(b[1])
+	size := 2 + n*10
+	if len(b) < size {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		size += int(b[2+n*8+i])
+	}
+	return len(b) >= size
+}
+
+// reads the next advertisement from a capture, and when it was received
+type replayReader func() (time.Time, *replayedAdvertisement, error)
+
+// read advertisements from a btsnoop capture, pairing scan responses with
+// the advertisements they answer like the HCI does
+func btsnoopReader(r *bufio.Reader) (replayReader, error) {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(header[12:]) != btsnoopH4 {
+		return nil, errors.New("unsupported btsnoop data link type, must be HCI UART (H4)")
+	}
+	ads := map[string]*replayedAdvertisement{}
+	return func() (time.Time, *replayedAdvertisement, error) {
+		for {
+			record := make([]byte, 24)
+			_, err := io.ReadFull(r, record)
+			if err != nil {
+				return time.Time{}, nil, err
+			}
+			packet := make([]byte, binary.BigEndian.Uint32(record[4:]))
+			_, err = io.ReadFull(r, packet)
+			if err != nil {
+				return time.Time{}, nil, err
+			}
+			t := time.UnixMicro(int64(binary.BigEndian.Uint64(record[16:])) - btsnoopEpoch)
+			// only LE advertising reports, which are LE Meta events
+			if len(packet) < 3 || packet[0] != 0x04 || packet[1] != evt.LEAdvertisingReportCode ||
+				!validReport(packet[3:]) {
+				continue
+			}
+			a := &replayedAdvertisement{e: packet[3:]}
+			address := a.Addr().String()
+			if a.e.EventType(0) != 0x04 {
+				ads[address] = a
+				return t, a, nil
+			}
+			// a scan response
+			previous, ok := ads[address]
+			if !ok {
+				continue
+			}
+			previous.sr = a.e
+			return t, previous, nil
+		}
+	}, nil
+}
+
+// read advertisements from lines of JSON devices, as written by -ndjson and
+// the export command
+func ndjsonReader(r *bufio.Reader) replayReader {
+	decoder := json.NewDecoder(r)
+	return func() (time.Time, *replayedAdvertisement, error) {
+		for {
+			device := Device{}
+			err := decoder.Decode(&device)
+			if err != nil {
+				return time.Time{}, nil, err
+			}
+			a := &replayedAdvertisement{e: unformatHex(device.Advertisement)}
+			if !validReport(a.e) {
+				continue
+			}
+			if sr := unformatHex(device.ScanResponse); validReport(sr) {
+				a.sr = sr
+			}
+			return device.Detected, a, nil
+		}
+	}
+}
+
+// replayScanner feeds the advertisements in a btsnoop or NDJSON capture
+// through, at the speed they were received times speed, or as fast as
+// possible if speed is 0
+type replayScanner struct {
+	file  *os.File
+	next  replayReader
+	speed float64
+	count int
+	// when the last advertisement was received
+	last time.Time
+	// the advertisement read but not replayed before the last scan ended
+	pending     *replayedAdvertisement
+	pendingTime time.Time
+}
+
+// open the capture to replay
+func openReplay(path string, speed float64) (Scanner, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &replayScanner{file: file, speed: speed}
+	r := bufio.NewReader(file)
+	magic, _ := r.Peek(8)
+	if bytes.Equal(magic, []byte("btsnoop\x00")) {
+		s.next, err = btsnoopReader(r)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else {
+		s.next = ndjsonReader(r)
+	}
+	logger.Println("Replaying", path)
+	return s, nil
+}
+
+// the capture is replayed as it was recorded, whatever the settings
+func (s *replayScanner) Configure(settings ScanSettings) error {
+	return nil
+}
+
+// replay the capture until the context is done or the capture ends
+func (s *replayScanner) Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error {
+	for {
+		if s.pending == nil {
+			t, a, err := s.next()
+			if err != nil {
+				if err != io.EOF {
+					logger.Println("Cannot read capture, stopping:", err)
+				}
+				logger.Println("Replayed", s.count, "advertisements from", s.file.Name())
+				return io.EOF
+			}
+			s.pending, s.pendingTime = a, t
+		}
+		wait := time.Duration(0)
+		if s.speed > 0 && !s.last.IsZero() && s.pendingTime.After(s.last) {
+			wait = time.Duration(float64(s.pendingTime.Sub(s.last)) / s.speed)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		s.last = s.pendingTime
+		handler(s.pending)
+		s.pending = nil
+		s.count++
+	}
+}
+
+func (s *replayScanner) Unplugged() bool {
+	return false
+}
+
+func (s *replayScanner) Address() string {
+	return ""
+}
+
+func (s *replayScanner) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,1072 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: blueblue.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Device struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Address           string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Detected          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=detected,proto3" json:"detected,omitempty"`
+	Name              string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Vendor            string                 `protobuf:"bytes,4,opt,name=vendor,proto3" json:"vendor,omitempty"`
+	Rssi              int32                  `protobuf:"varint,5,opt,name=rssi,proto3" json:"rssi,omitempty"`
+	Adapters          map[string]*Sighting   `protobuf:"bytes,6,rep,name=adapters,proto3" json:"adapters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Advertisement     []byte                 `protobuf:"bytes,7,opt,name=advertisement,proto3" json:"advertisement,omitempty"`
+	ScanResponse      []byte                 `protobuf:"bytes,8,opt,name=scan_response,json=scanResponse,proto3" json:"scan_response,omitempty"`
+	Battery           *Battery               `protobuf:"bytes,9,opt,name=battery,proto3" json:"battery,omitempty"`
+	FirstSeen         *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"`
+	Packets           int32                  `protobuf:"varint,11,opt,name=packets,proto3" json:"packets,omitempty"`
+	PacketsLastMinute int32                  `protobuf:"varint,12,opt,name=packets_last_minute,json=packetsLastMinute,proto3" json:"packets_last_minute,omitempty"`
+	Interval          float64                `protobuf:"fixed64,13,opt,name=interval,proto3" json:"interval,omitempty"`
+	SmoothedRssi      float64                `protobuf:"fixed64,14,opt,name=smoothed_rssi,json=smoothedRssi,proto3" json:"smoothed_rssi,omitempty"`
+	Distance          float64                `protobuf:"fixed64,15,opt,name=distance,proto3" json:"distance,omitempty"`
+	TxPower           *int32                 `protobuf:"varint,16,opt,name=tx_power,json=txPower,proto3,oneof" json:"tx_power,omitempty"`
+	AddressType       string                 `protobuf:"bytes,17,opt,name=address_type,json=addressType,proto3" json:"address_type,omitempty"`
+	PrivateAddress    string                 `protobuf:"bytes,18,opt,name=private_address,json=privateAddress,proto3" json:"private_address,omitempty"`
+	Group             string                 `protobuf:"bytes,19,opt,name=group,proto3" json:"group,omitempty"`
+	Connectable       bool                   `protobuf:"varint,20,opt,name=connectable,proto3" json:"connectable,omitempty"`
+	Appearance        string                 `protobuf:"bytes,21,opt,name=appearance,proto3" json:"appearance,omitempty"`
+	Services          []*Service             `protobuf:"bytes,22,rep,name=services,proto3" json:"services,omitempty"`
+	Sensor            *Sensor                `protobuf:"bytes,23,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	Continuity        []string               `protobuf:"bytes,24,rep,name=continuity,proto3" json:"continuity,omitempty"`
+	Tracker           *Tracker               `protobuf:"bytes,25,opt,name=tracker,proto3" json:"tracker,omitempty"`
+	Tags              []string               `protobuf:"bytes,26,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Device) Reset() {
+	*x = Device{}
+	mi := &file_blueblue_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Device) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Device) ProtoMessage() {}
+
+func (x *Device) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Device.ProtoReflect.Descriptor instead.
+func (*Device) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Device) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Device) GetDetected() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Detected
+	}
+	return nil
+}
+
+func (x *Device) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Device) GetVendor() string {
+	if x != nil {
+		return x.Vendor
+	}
+	return ""
+}
+
+func (x *Device) GetRssi() int32 {
+	if x != nil {
+		return x.Rssi
+	}
+	return 0
+}
+
+func (x *Device) GetAdapters() map[string]*Sighting {
+	if x != nil {
+		return x.Adapters
+	}
+	return nil
+}
+
+func (x *Device) GetAdvertisement() []byte {
+	if x != nil {
+		return x.Advertisement
+	}
+	return nil
+}
+
+func (x *Device) GetScanResponse() []byte {
+	if x != nil {
+		return x.ScanResponse
+	}
+	return nil
+}
+
+func (x *Device) GetBattery() *Battery {
+	if x != nil {
+		return x.Battery
+	}
+	return nil
+}
+
+func (x *Device) GetFirstSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return nil
+}
+
+func (x *Device) GetPackets() int32 {
+	if x != nil {
+		return x.Packets
+	}
+	return 0
+}
+
+func (x *Device) GetPacketsLastMinute() int32 {
+	if x != nil {
+		return x.PacketsLastMinute
+	}
+	return 0
+}
+
+func (x *Device) GetInterval() float64 {
+	if x != nil {
+		return x.Interval
+	}
+	return 0
+}
+
+func (x *Device) GetSmoothedRssi() float64 {
+	if x != nil {
+		return x.SmoothedRssi
+	}
+	return 0
+}
+
+func (x *Device) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+func (x *Device) GetTxPower() int32 {
+	if x != nil && x.TxPower != nil {
+		return *x.TxPower
+	}
+	return 0
+}
+
+func (x *Device) GetAddressType() string {
+	if x != nil {
+		return x.AddressType
+	}
+	return ""
+}
+
+func (x *Device) GetPrivateAddress() string {
+	if x != nil {
+		return x.PrivateAddress
+	}
+	return ""
+}
+
+func (x *Device) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *Device) GetConnectable() bool {
+	if x != nil {
+		return x.Connectable
+	}
+	return false
+}
+
+func (x *Device) GetAppearance() string {
+	if x != nil {
+		return x.Appearance
+	}
+	return ""
+}
+
+func (x *Device) GetServices() []*Service {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *Device) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+func (x *Device) GetContinuity() []string {
+	if x != nil {
+		return x.Continuity
+	}
+	return nil
+}
+
+func (x *Device) GetTracker() *Tracker {
+	if x != nil {
+		return x.Tracker
+	}
+	return nil
+}
+
+func (x *Device) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type Tracker struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	Known         bool                   `protobuf:"varint,3,opt,name=known,proto3" json:"known,omitempty"`
+	Alerted       bool                   `protobuf:"varint,4,opt,name=alerted,proto3" json:"alerted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Tracker) Reset() {
+	*x = Tracker{}
+	mi := &file_blueblue_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tracker) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tracker) ProtoMessage() {}
+
+func (x *Tracker) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tracker.ProtoReflect.Descriptor instead.
+func (*Tracker) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Tracker) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Tracker) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *Tracker) GetKnown() bool {
+	if x != nil {
+		return x.Known
+	}
+	return false
+}
+
+func (x *Tracker) GetAlerted() bool {
+	if x != nil {
+		return x.Alerted
+	}
+	return false
+}
+
+type Sensor struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Format        string                 `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	Temperature   *float64               `protobuf:"fixed64,2,opt,name=temperature,proto3,oneof" json:"temperature,omitempty"`
+	Humidity      *float64               `protobuf:"fixed64,3,opt,name=humidity,proto3,oneof" json:"humidity,omitempty"`
+	Pressure      *float64               `protobuf:"fixed64,4,opt,name=pressure,proto3,oneof" json:"pressure,omitempty"`
+	Battery       *int32                 `protobuf:"varint,5,opt,name=battery,proto3,oneof" json:"battery,omitempty"`
+	Voltage       *float64               `protobuf:"fixed64,6,opt,name=voltage,proto3,oneof" json:"voltage,omitempty"`
+	Buttons       []string               `protobuf:"bytes,7,rep,name=buttons,proto3" json:"buttons,omitempty"`
+	Values        map[string]float64     `protobuf:"bytes,8,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sensor) Reset() {
+	*x = Sensor{}
+	mi := &file_blueblue_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sensor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sensor) ProtoMessage() {}
+
+func (x *Sensor) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sensor.ProtoReflect.Descriptor instead.
+func (*Sensor) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Sensor) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *Sensor) GetTemperature() float64 {
+	if x != nil && x.Temperature != nil {
+		return *x.Temperature
+	}
+	return 0
+}
+
+func (x *Sensor) GetHumidity() float64 {
+	if x != nil && x.Humidity != nil {
+		return *x.Humidity
+	}
+	return 0
+}
+
+func (x *Sensor) GetPressure() float64 {
+	if x != nil && x.Pressure != nil {
+		return *x.Pressure
+	}
+	return 0
+}
+
+func (x *Sensor) GetBattery() int32 {
+	if x != nil && x.Battery != nil {
+		return *x.Battery
+	}
+	return 0
+}
+
+func (x *Sensor) GetVoltage() float64 {
+	if x != nil && x.Voltage != nil {
+		return *x.Voltage
+	}
+	return 0
+}
+
+func (x *Sensor) GetButtons() []string {
+	if x != nil {
+		return x.Buttons
+	}
+	return nil
+}
+
+func (x *Sensor) GetValues() map[string]float64 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type Service struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Uuid          string                 `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Service) Reset() {
+	*x = Service{}
+	mi := &file_blueblue_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Service) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Service) ProtoMessage() {}
+
+func (x *Service) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Service.ProtoReflect.Descriptor instead.
+func (*Service) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Service) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *Service) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type Sighting struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rssi          int32                  `protobuf:"varint,1,opt,name=rssi,proto3" json:"rssi,omitempty"`
+	Detected      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=detected,proto3" json:"detected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sighting) Reset() {
+	*x = Sighting{}
+	mi := &file_blueblue_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sighting) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sighting) ProtoMessage() {}
+
+func (x *Sighting) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sighting.ProtoReflect.Descriptor instead.
+func (*Sighting) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Sighting) GetRssi() int32 {
+	if x != nil {
+		return x.Rssi
+	}
+	return 0
+}
+
+func (x *Sighting) GetDetected() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Detected
+	}
+	return nil
+}
+
+type Battery struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Level         int32                  `protobuf:"varint,1,opt,name=level,proto3" json:"level,omitempty"`
+	Read          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=read,proto3" json:"read,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Battery) Reset() {
+	*x = Battery{}
+	mi := &file_blueblue_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Battery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Battery) ProtoMessage() {}
+
+func (x *Battery) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Battery.ProtoReflect.Descriptor instead.
+func (*Battery) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Battery) GetLevel() int32 {
+	if x != nil {
+		return x.Level
+	}
+	return 0
+}
+
+func (x *Battery) GetRead() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Read
+	}
+	return nil
+}
+
+type ListDevicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinRssi       int32                  `protobuf:"varint,1,opt,name=min_rssi,json=minRssi,proto3" json:"min_rssi,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesRequest) Reset() {
+	*x = ListDevicesRequest{}
+	mi := &file_blueblue_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesRequest) ProtoMessage() {}
+
+func (x *ListDevicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesRequest.ProtoReflect.Descriptor instead.
+func (*ListDevicesRequest) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListDevicesRequest) GetMinRssi() int32 {
+	if x != nil {
+		return x.MinRssi
+	}
+	return 0
+}
+
+type ListDevicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Devices       []*Device              `protobuf:"bytes,1,rep,name=devices,proto3" json:"devices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesResponse) Reset() {
+	*x = ListDevicesResponse{}
+	mi := &file_blueblue_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesResponse) ProtoMessage() {}
+
+func (x *ListDevicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesResponse.ProtoReflect.Descriptor instead.
+func (*ListDevicesResponse) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListDevicesResponse) GetDevices() []*Device {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+type WatchDevicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinRssi       int32                  `protobuf:"varint,1,opt,name=min_rssi,json=minRssi,proto3" json:"min_rssi,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchDevicesRequest) Reset() {
+	*x = WatchDevicesRequest{}
+	mi := &file_blueblue_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchDevicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchDevicesRequest) ProtoMessage() {}
+
+func (x *WatchDevicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchDevicesRequest.ProtoReflect.Descriptor instead.
+func (*WatchDevicesRequest) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchDevicesRequest) GetMinRssi() int32 {
+	if x != nil {
+		return x.MinRssi
+	}
+	return 0
+}
+
+type DeviceEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Device        *Device                `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceEvent) Reset() {
+	*x = DeviceEvent{}
+	mi := &file_blueblue_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceEvent) ProtoMessage() {}
+
+func (x *DeviceEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceEvent.ProtoReflect.Descriptor instead.
+func (*DeviceEvent) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeviceEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DeviceEvent) GetDevice() *Device {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+type StartScanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartScanRequest) Reset() {
+	*x = StartScanRequest{}
+	mi := &file_blueblue_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartScanRequest) ProtoMessage() {}
+
+func (x *StartScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartScanRequest.ProtoReflect.Descriptor instead.
+func (*StartScanRequest) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{10}
+}
+
+type StopScanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopScanRequest) Reset() {
+	*x = StopScanRequest{}
+	mi := &file_blueblue_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopScanRequest) ProtoMessage() {}
+
+func (x *StopScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopScanRequest.ProtoReflect.Descriptor instead.
+func (*StopScanRequest) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{11}
+}
+
+type ScanResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Scanning      bool                   `protobuf:"varint,1,opt,name=scanning,proto3" json:"scanning,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanResponse) Reset() {
+	*x = ScanResponse{}
+	mi := &file_blueblue_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanResponse) ProtoMessage() {}
+
+func (x *ScanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blueblue_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanResponse.ProtoReflect.Descriptor instead.
+func (*ScanResponse) Descriptor() ([]byte, []int) {
+	return file_blueblue_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ScanResponse) GetScanning() bool {
+	if x != nil {
+		return x.Scanning
+	}
+	return false
+}
+
+var File_blueblue_proto protoreflect.FileDescriptor
+
+const file_blueblue_proto_rawDesc = "" +
+	"\n" +
+	"\x0eblueblue.proto\x12\bblueblue\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8c\b\n" +
+	"\x06Device\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x126\n" +
+	"\bdetected\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bdetected\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x16\n" +
+	"\x06vendor\x18\x04 \x01(\tR\x06vendor\x12\x12\n" +
+	"\x04rssi\x18\x05 \x01(\x05R\x04rssi\x12:\n" +
+	"\badapters\x18\x06 \x03(\v2\x1e.blueblue.Device.AdaptersEntryR\badapters\x12$\n" +
+	"\radvertisement\x18\a \x01(\fR\radvertisement\x12#\n" +
+	"\rscan_response\x18\b \x01(\fR\fscanResponse\x12+\n" +
+	"\abattery\x18\t \x01(\v2\x11.blueblue.BatteryR\abattery\x129\n" +
+	"\n" +
+	"first_seen\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tfirstSeen\x12\x18\n" +
+	"\apackets\x18\v \x01(\x05R\apackets\x12.\n" +
+	"\x13packets_last_minute\x18\f \x01(\x05R\x11packetsLastMinute\x12\x1a\n" +
+	"\binterval\x18\r \x01(\x01R\binterval\x12#\n" +
+	"\rsmoothed_rssi\x18\x0e \x01(\x01R\fsmoothedRssi\x12\x1a\n" +
+	"\bdistance\x18\x0f \x01(\x01R\bdistance\x12\x1e\n" +
+	"\btx_power\x18\x10 \x01(\x05H\x00R\atxPower\x88\x01\x01\x12!\n" +
+	"\faddress_type\x18\x11 \x01(\tR\vaddressType\x12'\n" +
+	"\x0fprivate_address\x18\x12 \x01(\tR\x0eprivateAddress\x12\x14\n" +
+	"\x05group\x18\x13 \x01(\tR\x05group\x12 \n" +
+	"\vconnectable\x18\x14 \x01(\bR\vconnectable\x12\x1e\n" +
+	"\n" +
+	"appearance\x18\x15 \x01(\tR\n" +
+	"appearance\x12-\n" +
+	"\bservices\x18\x16 \x03(\v2\x11.blueblue.ServiceR\bservices\x12(\n" +
+	"\x06sensor\x18\x17 \x01(\v2\x10.blueblue.SensorR\x06sensor\x12\x1e\n" +
+	"\n" +
+	"continuity\x18\x18 \x03(\tR\n" +
+	"continuity\x12+\n" +
+	"\atracker\x18\x19 \x01(\v2\x11.blueblue.TrackerR\atracker\x12\x12\n" +
+	"\x04tags\x18\x1a \x03(\tR\x04tags\x1aO\n" +
+	"\rAdaptersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\v2\x12.blueblue.SightingR\x05value:\x028\x01B\v\n" +
+	"\t_tx_power\"\x7f\n" +
+	"\aTracker\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x120\n" +
+	"\x05since\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x12\x14\n" +
+	"\x05known\x18\x03 \x01(\bR\x05known\x12\x18\n" +
+	"\aalerted\x18\x04 \x01(\bR\aalerted\"\x94\x03\n" +
+	"\x06Sensor\x12\x16\n" +
+	"\x06format\x18\x01 \x01(\tR\x06format\x12%\n" +
+	"\vtemperature\x18\x02 \x01(\x01H\x00R\vtemperature\x88\x01\x01\x12\x1f\n" +
+	"\bhumidity\x18\x03 \x01(\x01H\x01R\bhumidity\x88\x01\x01\x12\x1f\n" +
+	"\bpressure\x18\x04 \x01(\x01H\x02R\bpressure\x88\x01\x01\x12\x1d\n" +
+	"\abattery\x18\x05 \x01(\x05H\x03R\abattery\x88\x01\x01\x12\x1d\n" +
+	"\avoltage\x18\x06 \x01(\x01H\x04R\avoltage\x88\x01\x01\x12\x18\n" +
+	"\abuttons\x18\a \x03(\tR\abuttons\x124\n" +
+	"\x06values\x18\b \x03(\v2\x1c.blueblue.Sensor.ValuesEntryR\x06values\x1a9\n" +
+	"\vValuesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01B\x0e\n" +
+	"\f_temperatureB\v\n" +
+	"\t_humidityB\v\n" +
+	"\t_pressureB\n" +
+	"\n" +
+	"\b_batteryB\n" +
+	"\n" +
+	"\b_voltage\"1\n" +
+	"\aService\x12\x12\n" +
+	"\x04uuid\x18\x01 \x01(\tR\x04uuid\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"V\n" +
+	"\bSighting\x12\x12\n" +
+	"\x04rssi\x18\x01 \x01(\x05R\x04rssi\x126\n" +
+	"\bdetected\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bdetected\"O\n" +
+	"\aBattery\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\x05R\x05level\x12.\n" +
+	"\x04read\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04read\"/\n" +
+	"\x12ListDevicesRequest\x12\x19\n" +
+	"\bmin_rssi\x18\x01 \x01(\x05R\aminRssi\"A\n" +
+	"\x13ListDevicesResponse\x12*\n" +
+	"\adevices\x18\x01 \x03(\v2\x10.blueblue.DeviceR\adevices\"0\n" +
+	"\x13WatchDevicesRequest\x12\x19\n" +
+	"\bmin_rssi\x18\x01 \x01(\x05R\aminRssi\"K\n" +
+	"\vDeviceEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12(\n" +
+	"\x06device\x18\x02 \x01(\v2\x10.blueblue.DeviceR\x06device\"\x12\n" +
+	"\x10StartScanRequest\"\x11\n" +
+	"\x0fStopScanRequest\"*\n" +
+	"\fScanResponse\x12\x1a\n" +
+	"\bscanning\x18\x01 \x01(\bR\bscanning2\x9e\x02\n" +
+	"\bBlueBlue\x12J\n" +
+	"\vListDevices\x12\x1c.blueblue.ListDevicesRequest\x1a\x1d.blueblue.ListDevicesResponse\x12F\n" +
+	"\fWatchDevices\x12\x1d.blueblue.WatchDevicesRequest\x1a\x15.blueblue.DeviceEvent0\x01\x12?\n" +
+	"\tStartScan\x12\x1a.blueblue.StartScanRequest\x1a\x16.blueblue.ScanResponse\x12=\n" +
+	"\bStopScan\x12\x19.blueblue.StopScanRequest\x1a\x16.blueblue.ScanResponseB#Z!github.com/sausheong/blueblue/rpcb\x06proto3"
+
+var (
+	file_blueblue_proto_rawDescOnce sync.Once
+	file_blueblue_proto_rawDescData []byte
+)
+
+func file_blueblue_proto_rawDescGZIP() []byte {
+	file_blueblue_proto_rawDescOnce.Do(func() {
+		file_blueblue_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_blueblue_proto_rawDesc), len(file_blueblue_proto_rawDesc)))
+	})
+	return file_blueblue_proto_rawDescData
+}
+
+var file_blueblue_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_blueblue_proto_goTypes = []any{
+	(*Device)(nil),                // 0: blueblue.Device
+	(*Tracker)(nil),               // 1: blueblue.Tracker
+	(*Sensor)(nil),                // 2: blueblue.Sensor
+	(*Service)(nil),               // 3: blueblue.Service
+	(*Sighting)(nil),              // 4: blueblue.Sighting
+	(*Battery)(nil),               // 5: blueblue.Battery
+	(*ListDevicesRequest)(nil),    // 6: blueblue.ListDevicesRequest
+	(*ListDevicesResponse)(nil),   // 7: blueblue.ListDevicesResponse
+	(*WatchDevicesRequest)(nil),   // 8: blueblue.WatchDevicesRequest
+	(*DeviceEvent)(nil),           // 9: blueblue.DeviceEvent
+	(*StartScanRequest)(nil),      // 10: blueblue.StartScanRequest
+	(*StopScanRequest)(nil),       // 11: blueblue.StopScanRequest
+	(*ScanResponse)(nil),          // 12: blueblue.ScanResponse
+	nil,                           // 13: blueblue.Device.AdaptersEntry
+	nil,                           // 14: blueblue.Sensor.ValuesEntry
+	(*timestamppb.Timestamp)(nil), // 15: google.protobuf.Timestamp
+}
+var file_blueblue_proto_depIdxs = []int32{
+	15, // 0: blueblue.Device.detected:type_name -> google.protobuf.Timestamp
+	13, // 1: blueblue.Device.adapters:type_name -> blueblue.Device.AdaptersEntry
+	5,  // 2: blueblue.Device.battery:type_name -> blueblue.Battery
+	15, // 3: blueblue.Device.first_seen:type_name -> google.protobuf.Timestamp
+	3,  // 4: blueblue.Device.services:type_name -> blueblue.Service
+	2,  // 5: blueblue.Device.sensor:type_name -> blueblue.Sensor
+	1,  // 6: blueblue.Device.tracker:type_name -> blueblue.Tracker
+	15, // 7: blueblue.Tracker.since:type_name -> google.protobuf.Timestamp
+	14, // 8: blueblue.Sensor.values:type_name -> blueblue.Sensor.ValuesEntry
+	15, // 9: blueblue.Sighting.detected:type_name -> google.protobuf.Timestamp
+	15, // 10: blueblue.Battery.read:type_name -> google.protobuf.Timestamp
+	0,  // 11: blueblue.ListDevicesResponse.devices:type_name -> blueblue.Device
+	0,  // 12: blueblue.DeviceEvent.device:type_name -> blueblue.Device
+	4,  // 13: blueblue.Device.AdaptersEntry.value:type_name -> blueblue.Sighting
+	6,  // 14: blueblue.BlueBlue.ListDevices:input_type -> blueblue.ListDevicesRequest
+	8,  // 15: blueblue.BlueBlue.WatchDevices:input_type -> blueblue.WatchDevicesRequest
+	10, // 16: blueblue.BlueBlue.StartScan:input_type -> blueblue.StartScanRequest
+	11, // 17: blueblue.BlueBlue.StopScan:input_type -> blueblue.StopScanRequest
+	7,  // 18: blueblue.BlueBlue.ListDevices:output_type -> blueblue.ListDevicesResponse
+	9,  // 19: blueblue.BlueBlue.WatchDevices:output_type -> blueblue.DeviceEvent
+	12, // 20: blueblue.BlueBlue.StartScan:output_type -> blueblue.ScanResponse
+	12, // 21: blueblue.BlueBlue.StopScan:output_type -> blueblue.ScanResponse
+	18, // [18:22] is the sub-list for method output_type
+	14, // [14:18] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_blueblue_proto_init() }
+func file_blueblue_proto_init() {
+	if File_blueblue_proto != nil {
+		return
+	}
+	file_blueblue_proto_msgTypes[0].OneofWrappers = []any{}
+	file_blueblue_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_blueblue_proto_rawDesc), len(file_blueblue_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_blueblue_proto_goTypes,
+		DependencyIndexes: file_blueblue_proto_depIdxs,
+		MessageInfos:      file_blueblue_proto_msgTypes,
+	}.Build()
+	File_blueblue_proto = out.File
+	file_blueblue_proto_goTypes = nil
+	file_blueblue_proto_depIdxs = nil
+}
@@ -0,0 +1,239 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: blueblue.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	BlueBlue_ListDevices_FullMethodName  = "/blueblue.BlueBlue/ListDevices"
+	BlueBlue_WatchDevices_FullMethodName = "/blueblue.BlueBlue/WatchDevices"
+	BlueBlue_StartScan_FullMethodName    = "/blueblue.BlueBlue/StartScan"
+	BlueBlue_StopScan_FullMethodName     = "/blueblue.BlueBlue/StopScan"
+)
+
+// BlueBlueClient is the client API for BlueBlue service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BlueBlueClient interface {
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	WatchDevices(ctx context.Context, in *WatchDevicesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DeviceEvent], error)
+	StartScan(ctx context.Context, in *StartScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
+	StopScan(ctx context.Context, in *StopScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
+}
+
+type blueBlueClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBlueBlueClient(cc grpc.ClientConnInterface) BlueBlueClient {
+	return &blueBlueClient{cc}
+}
+
+func (c *blueBlueClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, BlueBlue_ListDevices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blueBlueClient) WatchDevices(ctx context.Context, in *WatchDevicesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DeviceEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BlueBlue_ServiceDesc.Streams[0], BlueBlue_WatchDevices_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchDevicesRequest, DeviceEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BlueBlue_WatchDevicesClient = grpc.ServerStreamingClient[DeviceEvent]
+
+func (c *blueBlueClient) StartScan(ctx context.Context, in *StartScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, BlueBlue_StartScan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blueBlueClient) StopScan(ctx context.Context, in *StopScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, BlueBlue_StopScan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlueBlueServer is the server API for BlueBlue service.
+// All implementations must embed UnimplementedBlueBlueServer
+// for forward compatibility.
+type BlueBlueServer interface {
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	WatchDevices(*WatchDevicesRequest, grpc.ServerStreamingServer[DeviceEvent]) error
+	StartScan(context.Context, *StartScanRequest) (*ScanResponse, error)
+	StopScan(context.Context, *StopScanRequest) (*ScanResponse, error)
+	mustEmbedUnimplementedBlueBlueServer()
+}
+
+// UnimplementedBlueBlueServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBlueBlueServer struct{}
+
+func (UnimplementedBlueBlueServer) ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedBlueBlueServer) WatchDevices(*WatchDevicesRequest, grpc.ServerStreamingServer[DeviceEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchDevices not implemented")
+}
+func (UnimplementedBlueBlueServer) StartScan(context.Context, *StartScanRequest) (*ScanResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method StartScan not implemented")
+}
+func (UnimplementedBlueBlueServer) StopScan(context.Context, *StopScanRequest) (*ScanResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method StopScan not implemented")
+}
+func (UnimplementedBlueBlueServer) mustEmbedUnimplementedBlueBlueServer() {}
+func (UnimplementedBlueBlueServer) testEmbeddedByValue()                  {}
+
+// UnsafeBlueBlueServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BlueBlueServer will
+// result in compilation errors.
+type UnsafeBlueBlueServer interface {
+	mustEmbedUnimplementedBlueBlueServer()
+}
+
+func RegisterBlueBlueServer(s grpc.ServiceRegistrar, srv BlueBlueServer) {
+	// If the following call panics, it indicates UnimplementedBlueBlueServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&BlueBlue_ServiceDesc, srv)
+}
+
+func _BlueBlue_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlueBlueServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BlueBlue_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlueBlueServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlueBlue_WatchDevices_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchDevicesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BlueBlueServer).WatchDevices(m, &grpc.GenericServerStream[WatchDevicesRequest, DeviceEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BlueBlue_WatchDevicesServer = grpc.ServerStreamingServer[DeviceEvent]
+
+func _BlueBlue_StartScan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlueBlueServer).StartScan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BlueBlue_StartScan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlueBlueServer).StartScan(ctx, req.(*StartScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlueBlue_StopScan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlueBlueServer).StopScan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BlueBlue_StopScan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlueBlueServer).StopScan(ctx, req.(*StopScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BlueBlue_ServiceDesc is the grpc.ServiceDesc for BlueBlue service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BlueBlue_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "blueblue.BlueBlue",
+	HandlerType: (*BlueBlueServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDevices",
+			Handler:    _BlueBlue_ListDevices_Handler,
+		},
+		{
+			MethodName: "StartScan",
+			Handler:    _BlueBlue_StartScan_Handler,
+		},
+		{
+			MethodName: "StopScan",
+			Handler:    _BlueBlue_StopScan_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchDevices",
+			Handler:       _BlueBlue_WatchDevices_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "blueblue.proto",
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventRule is published when a device starts matching a rule, and again
+// every time the rule repeats while it keeps matching
+const EventRule = "rule"
+
+// Rule is the conditions an advertisement is matched against, all of which
+// must hold, and the actions taken when a device matches. Conditions that
+// are left out match anything
+type Rule struct {
+	Name string `json:"name"`
+	// the address of the device, or the name of its identity
+	Address string `json:"address,omitempty"`
+	// regular expression the name of the device must match
+	NamePattern string `json:"namepattern,omitempty"`
+	// the RSSI the device must be seen with, 0 for any
+	MinRSSI int `json:"minrssi,omitempty"`
+	// ranges decoded sensor readings must be in, by name like temperature
+	Fields map[string]Range `json:"fields,omitempty"`
+	// how long the device must have been visible, in seconds
+	Dwell float64 `json:"dwell,omitempty"`
+
+	// URL to post the rule event to, MQTT topic to publish the device to,
+	// whether to log the match and the tag the device gets while it matches
+	Webhook   string `json:"webhook,omitempty"`
+	MQTTTopic string `json:"mqtttopic,omitempty"`
+	Log       bool   `json:"log,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	// how often to act again while the device keeps matching, in seconds, 0
+	// to only act when it starts matching
+	Repeat float64 `json:"repeat,omitempty"`
+}
+
+// Range is the lowest and highest a reading can be, either can be left out
+type Range struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// compiled name patterns of the rules
+var (
+	patterns      = map[string]*regexp.Regexp{}
+	patternsMutex sync.Mutex
+)
+
+// ruleMatch is a device matching a rule
+type ruleMatch struct {
+	rule, address string
+}
+
+// when each rule last acted on each device, protected by the device mutex
+var ruleActions = map[ruleMatch]time.Time{}
+
+// the compiled name pattern
+func namePattern(pattern string) (*regexp.Regexp, error) {
+	patternsMutex.Lock()
+	defer patternsMutex.Unlock()
+	if re, ok := patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns[pattern] = re
+	return re, nil
+}
+
+// check the rule is usable
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("invalid rule, must have a name")
+	}
+	if r.NamePattern != "" {
+		_, err := namePattern(r.NamePattern)
+		if err != nil {
+			return fmt.Errorf("invalid name pattern for rule %s: %v", r.Name, err)
+		}
+	}
+	if r.MinRSSI > 0 {
+		return fmt.Errorf("invalid minimum RSSI %d for rule %s, must be 0 or less", r.MinRSSI, r.Name)
+	}
+	for field, rng := range r.Fields {
+		if rng.Min != nil && rng.Max != nil && *rng.Min > *rng.Max {
+			return fmt.Errorf("invalid range of %s for rule %s, the minimum is more than the maximum", field, r.Name)
+		}
+	}
+	if r.Dwell < 0 || r.Repeat < 0 {
+		return fmt.Errorf("invalid dwell or repeat for rule %s, must be 0s or more", r.Name)
+	}
+	return nil
+}
+
+// check if the device matches the rule
+func (r Rule) matches(device Device) bool {
+	if r.Address != "" && !strings.EqualFold(r.Address, device.Address) {
+		return false
+	}
+	if r.NamePattern != "" {
+		re, err := namePattern(r.NamePattern)
+		if err != nil || !re.MatchString(device.Name) {
+			return false
+		}
+	}
+	if r.MinRSSI != 0 && device.RSSI < r.MinRSSI {
+		return false
+	}
+	if len(r.Fields) > 0 {
+		if device.Sensor == nil {
+			return false
+		}
+		readings := device.Sensor.readings()
+		for field, rng := range r.Fields {
+			value, ok := readings[field]
+			if !ok || rng.Min != nil && value < *rng.Min || rng.Max != nil && value > *rng.Max {
+				return false
+			}
+		}
+	}
+	dwell := time.Duration(r.Dwell * float64(time.Second))
+	return device.Detected.Sub(device.FirstSeen) >= dwell
+}
+
+// match the device against the rules, tagging it with the tags of the rules
+// it matches, and return the rules to act on, those that it just started
+// matching or that are due to repeat. Must be called with the device mutex
+// held
+func applyRules(device *Device, c Config) []Rule {
+	acting := []Rule{}
+	device.Tags = nil
+	for _, r := range c.Rules {
+		key := ruleMatch{r.Name, device.Address}
+		if !r.matches(*device) {
+			delete(ruleActions, key)
+			continue
+		}
+		if r.Tag != "" {
+			device.Tags = append(device.Tags, r.Tag)
+		}
+		last, ok := ruleActions[key]
+		if ok && (r.Repeat == 0 || device.Detected.Sub(last) < time.Duration(r.Repeat*float64(time.Second))) {
+			continue
+		}
+		ruleActions[key] = device.Detected
+		acting = append(acting, r)
+	}
+	return acting
+}
+
+// forget when the rules acted on the devices that are gone, must be called
+// with the device mutex held
+func expireRules() {
+	for key := range ruleActions {
+		if _, ok := devices[key.address]; !ok {
+			delete(ruleActions, key)
+		}
+	}
+}
+
+// take the actions of the rule on the device, and publish the rule event
+func actOnRule(r Rule, device Device) {
+	e := Event{Type: EventRule, Device: device, Rule: r.Name}
+	broker.Publish(e)
+	if r.Log {
+		logger.Println("Rule", r.Name, "matched", device.Address, device.Name, device.RSSI)
+	}
+	if r.Webhook != "" {
+		go retry("rule "+r.Name, func() error { return postJSON(r.Webhook, e) })
+	}
+	if r.MQTTTopic != "" && mqttClient != nil {
+		payload, err := json.Marshal(device)
+		if err != nil {
+			logger.Println("Cannot encode device for MQTT:", err)
+			return
+		}
+		mqttClient.Publish(r.MQTTTopic, byte(currentConfig().MQTTQoS), false, payload)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRulesActOnceUnlessRepeated(t *testing.T) {
+	devices = map[string]Device{}
+	ruleActions = map[ruleMatch]time.Time{}
+	c := Config{Rules: []Rule{
+		{Name: "once", Address: "alice phone", Tag: "home"},
+		{Name: "every minute", NamePattern: "^Alice", Repeat: 60},
+	}}
+	now := time.Now()
+	device := Device{Address: "alice phone", Name: "Alice's iPhone", FirstSeen: now}
+	tests := []struct {
+		after time.Duration
+		want  []string
+	}{
+		{0, []string{"once", "every minute"}},
+		{time.Second, nil},
+		{30 * time.Second, nil},
+		{61 * time.Second, []string{"every minute"}},
+	}
+	for _, test := range tests {
+		device.Detected = now.Add(test.after)
+		acting := applyRules(&device, c)
+		devices[device.Address] = device
+		expireRules()
+		names := []string{}
+		for _, r := range acting {
+			names = append(names, r.Name)
+		}
+		if len(names) != len(test.want) {
+			t.Fatalf("after %v acted on %v, want %v", test.after, names, test.want)
+		}
+		for i := range names {
+			if names[i] != test.want[i] {
+				t.Errorf("after %v acted on %v, want %v", test.after, names, test.want)
+			}
+		}
+		if len(device.Tags) != 1 || device.Tags[0] != "home" {
+			t.Errorf("after %v tagged %v, want [home]", test.after, device.Tags)
+		}
+	}
+	delete(devices, device.Address)
+	expireRules()
+	if len(ruleActions) != 0 {
+		t.Errorf("%d rule actions left after the device expired", len(ruleActions))
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	min, max := 8.0, 30.0
+	temperature := 9.5
+	device := Device{Address: "aa:bb", Name: "ATC_1", RSSI: -60, Sensor: &Sensor{Temperature: &temperature}}
+	device.Detected = time.Now()
+	device.FirstSeen = device.Detected.Add(-time.Minute)
+	tests := []struct {
+		rule Rule
+		want bool
+	}{
+		{Rule{Name: "any"}, true},
+		{Rule{Name: "address", Address: "AA:BB"}, true},
+		{Rule{Name: "other address", Address: "cc:dd"}, false},
+		{Rule{Name: "name", NamePattern: "^ATC_"}, true},
+		{Rule{Name: "other name", NamePattern: "^LYWSD"}, false},
+		{Rule{Name: "near", MinRSSI: -70}, true},
+		{Rule{Name: "nearer", MinRSSI: -50}, false},
+		{Rule{Name: "warm", Fields: map[string]Range{"temperature": {Min: &min}}}, true},
+		{Rule{Name: "hot", Fields: map[string]Range{"temperature": {Min: &max}}}, false},
+		{Rule{Name: "humid", Fields: map[string]Range{"humidity": {Min: &min}}}, false},
+		{Rule{Name: "dwelled", Dwell: 30}, true},
+		{Rule{Name: "not dwelled", Dwell: 120}, false},
+	}
+	for _, test := range tests {
+		if got := test.rule.matches(device); got != test.want {
+			t.Errorf("rule %s matches = %v, want %v", test.rule.Name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Ruuvi Innovations company ID
+const ruuviCompany = 0x0499
+
+func init() {
+	registerManufacturerDataDecoder(ruuviCompany, "ruuvi3", decodeRuuvi3)
+	registerManufacturerDataDecoder(ruuviCompany, "ruuvi5", decodeRuuvi5)
+}
+
+// decode RuuviTag data format 3 (RAWv1), humidity in half percent, the
+// temperature as a sign and magnitude integer and hundredths, pressure in Pa
+// less 50000, acceleration in mG and battery in mV, big-endian
+func decodeRuuvi3(data []byte, sensor *Sensor) bool {
+	if len(data) != 14 || data[0] != 3 {
+		return false
+	}
+	humidity := float64(data[1]) / 2
+	temperature := float64(data[2]&0x7f) + float64(data[3])/100
+	if data[2]&0x80 != 0 {
+		temperature = -temperature
+	}
+	pressure := (float64(binary.BigEndian.Uint16(data[4:])) + 50000) / 100
+	voltage := float64(binary.BigEndian.Uint16(data[12:])) / 1000
+	sensor.Temperature, sensor.Humidity, sensor.Pressure = &temperature, &humidity, &pressure
+	sensor.Voltage = &voltage
+	sensor.setValues(map[string]float64{
+		"accelerationx": float64(int16(binary.BigEndian.Uint16(data[6:]))) / 1000,
+		"accelerationy": float64(int16(binary.BigEndian.Uint16(data[8:]))) / 1000,
+		"accelerationz": float64(int16(binary.BigEndian.Uint16(data[10:]))) / 1000,
+	})
+	return true
+}
+
+// decode RuuviTag data format 5 (RAWv2), temperature in 0.005 degrees,
+// humidity in 0.0025 percent, pressure in Pa less 50000, acceleration in mG,
+// battery in mV above 1600 and TX power, movement counter, measurement
+// sequence number and MAC address, big-endian. Each reading has a value
+// that means it's not available
+func decodeRuuvi5(data []byte, sensor *Sensor) bool {
+	if len(data) != 24 || data[0] != 5 {
+		return false
+	}
+	if raw := binary.BigEndian.Uint16(data[1:]); raw != 0x8000 {
+		temperature := math.Round(float64(int16(raw))*5) / 1000
+		sensor.Temperature = &temperature
+	}
+	if raw := binary.BigEndian.Uint16(data[3:]); raw != 0xffff {
+		humidity := math.Round(float64(raw)*25) / 10000
+		sensor.Humidity = &humidity
+	}
+	if raw := binary.BigEndian.Uint16(data[5:]); raw != 0xffff {
+		pressure := (float64(raw) + 50000) / 100
+		sensor.Pressure = &pressure
+	}
+	values := map[string]float64{}
+	for i, axis := range []string{"accelerationx", "accelerationy", "accelerationz"} {
+		if raw := binary.BigEndian.Uint16(data[7+2*i:]); raw != 0x8000 {
+			values[axis] = float64(int16(raw)) / 1000
+		}
+	}
+	power := binary.BigEndian.Uint16(data[13:])
+	if power>>5 != 2047 {
+		voltage := float64(power>>5+1600) / 1000
+		sensor.Voltage = &voltage
+	}
+	if data[15] != 255 {
+		values["movement"] = float64(data[15])
+	}
+	if raw := binary.BigEndian.Uint16(data[16:]); raw != 0xffff {
+		values["sequence"] = float64(raw)
+	}
+	sensor.setValues(values)
+	return true
+}
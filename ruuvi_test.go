@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+// the test vectors are from the Ruuvi sensor protocol specification
+
+func TestDecodeRuuvi3(t *testing.T) {
+	testDecoder(t, decodeRuuvi3, []decoderTest{
+		{"valid", "03291a1ece1efc18f94202ca0b53", map[string]float64{
+			"humidity": 20.5, "temperature": 26.3, "pressure": 1027.66, "voltage": 2.899,
+			"accelerationx": -1, "accelerationy": -1.726, "accelerationz": 0.714,
+		}},
+		{"maximum", "03ff7f63ffff7fff7fff7fff0bb8", map[string]float64{
+			"humidity": 127.5, "temperature": 127.99, "pressure": 1155.35, "voltage": 3,
+			"accelerationx": 32.767, "accelerationy": 32.767, "accelerationz": 32.767,
+		}},
+		{"minimum", "0300ff6300008001800180010000", map[string]float64{
+			"humidity": 0, "temperature": -127.99, "pressure": 500, "voltage": 0,
+			"accelerationx": -32.767, "accelerationy": -32.767, "accelerationz": -32.767,
+		}},
+		{"truncated", "03291a1ece1efc18f94202ca0b", nil},
+		{"format 5", "0512fc5394c37c0004fffc040cac364200cdcbb8334c884f", nil},
+	})
+}
+
+func TestDecodeRuuvi5(t *testing.T) {
+	testDecoder(t, decodeRuuvi5, []decoderTest{
+		{"valid", "0512fc5394c37c0004fffc040cac364200cdcbb8334c884f", map[string]float64{
+			"temperature": 24.3, "humidity": 53.49, "pressure": 1000.44, "voltage": 2.977,
+			"accelerationx": 0.004, "accelerationy": -0.004, "accelerationz": 1.036,
+			"movement": 66, "sequence": 205,
+		}},
+		{"maximum", "057fff9c40fffe7fff7fff7fffffdefefffecbb8334c884f", map[string]float64{
+			"temperature": 163.835, "humidity": 100, "pressure": 1155.34, "voltage": 3.646,
+			"accelerationx": 32.767, "accelerationy": 32.767, "accelerationz": 32.767,
+			"movement": 254, "sequence": 65534,
+		}},
+		{"minimum", "058001000000008001800180010000000000cbb8334c884f", map[string]float64{
+			"temperature": -163.835, "humidity": 0, "pressure": 500, "voltage": 1.6,
+			"accelerationx": -32.767, "accelerationy": -32.767, "accelerationz": -32.767,
+			"movement": 0, "sequence": 0,
+		}},
+		{"not available", "058000ffffffff800080008000ffffffffffffffffffffff", map[string]float64{}},
+		{"finest humidity", "0512fc5395c37c0004fffc040cac364200cdcbb8334c884f", map[string]float64{
+			"temperature": 24.3, "humidity": 53.4925, "pressure": 1000.44, "voltage": 2.977,
+			"accelerationx": 0.004, "accelerationy": -0.004, "accelerationz": 1.036,
+			"movement": 66, "sequence": 205,
+		}},
+		{"truncated", "0512fc5394c37c0004fffc040cac364200cdcbb8334c88", nil},
+		{"format 3", "03291a1ece1efc18f94202ca0b53", nil},
+	})
+}
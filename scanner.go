@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/sausheong/ble"
+	"github.com/sausheong/ble/linux"
+	"github.com/sausheong/ble/linux/hci/cmd"
+)
+
+// Scanner is what an adapter scans with, such as an HCI device, the
+// simulator or a capture being replayed
+type Scanner interface {
+	// program the scan settings, while not scanning
+	Configure(s ScanSettings) error
+	// call the handler with the advertisements received until the context is
+	// done, returns io.EOF if there will never be any more
+	Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error
+	// check if the scanner has gone away, such as an unplugged adapter
+	Unplugged() bool
+	// the Bluetooth address of the scanner, if it has one
+	Address() string
+	Close() error
+}
+
+// returned when opening an adapter that isn't in the system
+var errNotPresent = errors.New("not present")
+
+// returned when connecting or advertising without an HCI adapter to do it
+var errNoHCI = errors.New("the first adapter isn't an HCI adapter")
+
+// the names of the scanners that aren't HCI adapters
+const (
+	ScannerSimulator     = "simulator"
+	ScannerReplay        = "replay"
+	ScannerCoreBluetooth = "corebluetooth"
+	ScannerBlueZ         = "bluez"
+)
+
+// the functions that open the scanners that aren't HCI adapters, by name
+var scanners = map[string]func() (Scanner, error){
+	ScannerSimulator: openSimulator,
+	ScannerReplay: func() (Scanner, error) {
+		return openReplay(*replayPath, *replaySpeed)
+	},
+}
+
+// the adapter to scan with by default, CoreBluetooth on a Mac
+func defaultAdapter() string {
+	if runtime.GOOS == "darwin" {
+		return ScannerCoreBluetooth
+	}
+	return "hci0"
+}
+
+// the function that opens the scanner with the given name, an HCI adapter
+// name (hci0) or ID (0), an adapter through BlueZ (bluez or bluez:hci1), or
+// one of the other scanners
+func scannerOpener(name string) (id int, open func() (Scanner, error), err error) {
+	if open, ok := scanners[strings.TrimSpace(name)]; ok {
+		return -1, open, nil
+	}
+	if adapter, ok := bluezAdapterName(name); ok {
+		return -1, func() (Scanner, error) {
+			return openBlueZ(adapter)
+		}, nil
+	}
+	id, err = parseAdapter(name)
+	if err != nil {
+		return
+	}
+	return id, func() (Scanner, error) {
+		return openHCI(id)
+	}, nil
+}
+
+// hciScanner scans with a Linux HCI device
+type hciScanner struct {
+	*linux.Device
+	name string
+}
+
+// open the HCI device with the given ID
+func openHCI(id int) (Scanner, error) {
+	name := fmt.Sprintf("hci%d", id)
+	if _, err := os.Stat("/sys/class/bluetooth/" + name); os.IsNotExist(err) {
+		return nil, fmt.Errorf("can't open %s: %w", name, errNotPresent)
+	}
+	d, err := linux.NewDevice(ble.OptDeviceID(id))
+	if err != nil {
+		return nil, fmt.Errorf("can't open %s: %v", name, err)
+	}
+	return &hciScanner{Device: d, name: name}, nil
+}
+
+// program the controller with the whitelist and scan parameters
+func (h *hciScanner) Configure(s ScanSettings) error {
+	err := h.HCI.Send(&cmd.LEClearWhiteList{}, nil)
+	if err != nil {
+		return err
+	}
+	for _, address := range s.Whitelist {
+		b, _ := whitelistAddress(address)
+		// the address type isn't known, so add both public and random
+		for _, typ := range []uint8{0x00, 0x01} {
+			err = h.HCI.Send(&cmd.LEAddDeviceToWhiteList{AddressType: typ, Address: b}, nil)
+			if err != nil {
+				return fmt.Errorf("can't whitelist %s: %v", address, err)
+			}
+		}
+	}
+	return h.HCI.Send(scanParameters(s), nil)
+}
+
+func (h *hciScanner) Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error {
+	return h.Device.Scan(ble.WithSigHandler(context.WithCancel(ctx)), duplicates, handler)
+}
+
+// check if the adapter has been removed from the system, or its device
+// has been closed because the adapter went away
+func (h *hciScanner) Unplugged() bool {
+	_, err := os.Stat("/sys/class/bluetooth/" + h.name)
+	if os.IsNotExist(err) {
+		return true
+	}
+	select {
+	case <-h.HCI.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *hciScanner) Address() string {
+	return h.Device.Address().String()
+}
+
+func (h *hciScanner) Close() error {
+	return h.Device.Stop()
+}
@@ -0,0 +1,64 @@
+//go:build darwin && cgo
+
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sausheong/ble"
+	"github.com/sausheong/ble/darwin"
+)
+
+func init() {
+	scanners[ScannerCoreBluetooth] = openCoreBluetooth
+}
+
+// coreBluetoothScanner scans with CoreBluetooth on a Mac. CoreBluetooth
+// doesn't give the raw advertisements or the advertisers' addresses, devices
+// are identified by a UUID it makes up instead
+type coreBluetoothScanner struct {
+	*darwin.Device
+	whitelist map[string]bool
+}
+
+// open CoreBluetooth, which asks for permission to use Bluetooth the first
+// time
+func openCoreBluetooth() (Scanner, error) {
+	d, err := darwin.NewDevice()
+	if err != nil {
+		return nil, err
+	}
+	return &coreBluetoothScanner{Device: d}, nil
+}
+
+// CoreBluetooth picks the scan parameters itself, so only the whitelist is
+// applied, by the scanner instead of the controller
+func (c *coreBluetoothScanner) Configure(s ScanSettings) error {
+	c.whitelist = map[string]bool{}
+	for _, address := range s.Whitelist {
+		c.whitelist[strings.ToLower(address)] = true
+	}
+	return nil
+}
+
+func (c *coreBluetoothScanner) Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error {
+	return c.Device.Scan(ctx, duplicates, func(a ble.Advertisement) {
+		if len(c.whitelist) > 0 && !c.whitelist[strings.ToLower(a.Addr().String())] {
+			return
+		}
+		handler(a)
+	})
+}
+
+func (c *coreBluetoothScanner) Unplugged() bool {
+	return false
+}
+
+func (c *coreBluetoothScanner) Address() string {
+	return ""
+}
+
+func (c *coreBluetoothScanner) Close() error {
+	return c.Device.Stop()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schedule is a cron-like rule for when to scan, scanning is on during every
+// minute that matches any schedule
+type Schedule struct {
+	ID int `json:"id"`
+	// minute hour day-of-month month day-of-week, e.g. "* 8-17 * * 1-5"
+	Cron   string   `json:"cron"`
+	fields [5]field // minute, hour, day of month, month, day of week
+	// whether the day of month and day of week fields start with *, as
+	// when either does, both must match, and otherwise either can
+	anyDay, anyWeekday bool
+}
+
+// the values a cron field matches
+type field map[int]bool
+
+// the ranges of the cron fields, Sunday is both 0 and 7 in the day of week
+var cronRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+var schedules = map[int]*Schedule{}
+var scheduleID int
+var scheduleMutex sync.Mutex
+
+// parse a cron expression into a schedule
+func parseSchedule(cron string) (*Schedule, error) {
+	parts := strings.Fields(cron)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, must have 5 fields", cron)
+	}
+	s := &Schedule{Cron: strings.Join(parts, " ")}
+	for i, part := range parts {
+		f, err := parseField(part, cronRanges[i][0], cronRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", cron, err)
+		}
+		s.fields[i] = f
+	}
+	if s.fields[4][7] {
+		s.fields[4][0] = true
+	}
+	s.anyDay, s.anyWeekday = strings.HasPrefix(parts[2], "*"), strings.HasPrefix(parts[4], "*")
+	return s, nil
+}
+
+// parse a cron field, which is a comma-separated list of *, n or n-m, each
+// optionally followed by /step
+func parseField(part string, min, max int) (field, error) {
+	f := field{}
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step in %q", item)
+			}
+			step = n
+			item = item[:i]
+		}
+		from, to := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			n, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", item)
+			}
+			from, to = n, n
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", item)
+				}
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+		}
+		for n := from; n <= to; n += step {
+			f[n] = true
+		}
+	}
+	return f, nil
+}
+
+// check if the schedule matches the given time. Like cron, when both the day
+// of month and day of week are restricted, a day matching either will do
+func (s *Schedule) matches(t time.Time) bool {
+	values := [5]int{t.Minute(), t.Hour(), t.Day(), int(t.Month()), int(t.Weekday())}
+	// minute, hour and month
+	for _, i := range []int{0, 1, 3} {
+		if !s.fields[i][values[i]] {
+			return false
+		}
+	}
+	day, weekday := s.fields[2][values[2]], s.fields[4][values[4]]
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// add a schedule
+func addSchedule(cron string) (*Schedule, error) {
+	s, err := parseSchedule(cron)
+	if err != nil {
+		return nil, err
+	}
+	scheduleMutex.Lock()
+	scheduleID++
+	s.ID = scheduleID
+	schedules[s.ID] = s
+	scheduleMutex.Unlock()
+	logger.Println("Added schedule", s.ID, s.Cron)
+	return s, nil
+}
+
+// remove a schedule, returns false if there is no such schedule
+func removeSchedule(id int) bool {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	if _, ok := schedules[id]; !ok {
+		return false
+	}
+	delete(schedules, id)
+	logger.Println("Removed schedule", id)
+	return true
+}
+
+// list the schedules
+func scheduleList() []*Schedule {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	list := []*Schedule{}
+	for _, s := range schedules {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// check if scanning should be on at the given time, and if there are any
+// schedules at all
+func scheduled(t time.Time) (on bool, any bool) {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	for _, s := range schedules {
+		if s.matches(t) {
+			return true, true
+		}
+	}
+	return false, len(schedules) > 0
+}
+
+// start and stop scanning according to the schedules, every minute
+func runSchedules() {
+	var previous *bool
+	for {
+		previous = followSchedules(time.Now(), previous)
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+	}
+}
+
+// start or stop scanning if the schedules have changed their minds since
+// they were previously checked, so scanning can still be started or stopped
+// manually in between. Returns what the schedules say now, or nil if there
+// are none
+func followSchedules(t time.Time, previous *bool) *bool {
+	on, any := scheduled(t)
+	if !any {
+		return nil
+	}
+	if previous != nil && *previous == on {
+		return previous
+	}
+	if on && startScanning() {
+		logger.Println("Scheduled scanning to start")
+	} else if !on && stopScanning() {
+		logger.Println("Scheduled scanning to stop")
+	}
+	return &on
+}
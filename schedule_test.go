@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleMatches(t *testing.T) {
+	// Monday 1 July 2024, Sunday 7 July 2024 and Monday 8 July 2024, at 09:00
+	monday1 := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	sunday7 := time.Date(2024, 7, 7, 9, 0, 0, 0, time.UTC)
+	monday8 := time.Date(2024, 7, 8, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		cron string
+		at   time.Time
+		want bool
+	}{
+		{"* 8-17 * * 1-5", monday8, true},
+		{"* 8-17 * * 1-5", sunday7, false},
+		{"0 9 1 * 1", monday1, true},
+		{"0 9 1 * 1", monday8, true},
+		{"0 9 1 * 1", sunday7, false},
+		{"0 9 1 * *", monday8, false},
+		{"0 9 * * 1", monday8, true},
+		// a day of month starting with * still needs both to match
+		{"0 9 */2 * 1", sunday7, false},
+		{"0 9 */2 * 1", monday1, true},
+		{"0 9 * * 7", sunday7, true},
+		{"0 9 * * 0", sunday7, true},
+		{"0 9 * * 5-7", sunday7, true},
+		{"30 9 * * *", monday8, false},
+	}
+	for _, test := range tests {
+		s, err := parseSchedule(test.cron)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", test.cron, err)
+			continue
+		}
+		if got := s.matches(test.at); got != test.want {
+			t.Errorf("%q matches %v = %v, want %v", test.cron, test.at, got, test.want)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, cron := range []string{"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "* * * * 5-1", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseSchedule(cron); err == nil {
+			t.Errorf("parseSchedule(%q) succeeded, want an error", cron)
+		}
+	}
+}
+
+func TestFollowSchedulesBackToBack(t *testing.T) {
+	scanner := &countingScanner{}
+	adapters = []*Adapter{{Name: "counting", Scanner: scanner, state: AdapterOK}}
+	s, err := addSchedule("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeSchedule(s.ID)
+	nine := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	// on at 9:00 for a minute, on again at 9:00 the next day
+	var previous *bool
+	for _, at := range []time.Time{nine, nine.Add(time.Minute), nine.Add(24 * time.Hour)} {
+		previous = followSchedules(at, previous)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if stop.Load() {
+		t.Error("scanning isn't on after the schedule started it again")
+	}
+	previous = followSchedules(nine.Add(24*time.Hour+time.Minute), previous)
+	scanning.Wait()
+	if !stop.Load() || *previous {
+		t.Error("scanning isn't off after the schedule stopped it")
+	}
+	if scanner.max != 1 {
+		t.Errorf("%d scans ran at once, want 1", scanner.max)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/sausheong/ble"
+)
+
+// names of the 16-bit service UUIDs of the Bluetooth SIG, and those
+// assigned to its members that are often advertised [Assigned Numbers, 3.4]
+var serviceNames = map[string]string{
+	"1800": "Generic Access",
+	"1801": "Generic Attribute",
+	"1802": "Immediate Alert",
+	"1803": "Link Loss",
+	"1804": "Tx Power",
+	"1805": "Current Time",
+	"1806": "Reference Time Update",
+	"1807": "Next DST Change",
+	"1808": "Glucose",
+	"1809": "Health Thermometer",
+	"180a": "Device Information",
+	"180d": "Heart Rate",
+	"180e": "Phone Alert Status",
+	"180f": "Battery",
+	"1810": "Blood Pressure",
+	"1811": "Alert Notification",
+	"1812": "Human Interface Device",
+	"1813": "Scan Parameters",
+	"1814": "Running Speed and Cadence",
+	"1815": "Automation IO",
+	"1816": "Cycling Speed and Cadence",
+	"1818": "Cycling Power",
+	"1819": "Location and Navigation",
+	"181a": "Environmental Sensing",
+	"181b": "Body Composition",
+	"181c": "User Data",
+	"181d": "Weight Scale",
+	"181e": "Bond Management",
+	"181f": "Continuous Glucose Monitoring",
+	"1820": "Internet Protocol Support",
+	"1821": "Indoor Positioning",
+	"1822": "Pulse Oximeter",
+	"1823": "HTTP Proxy",
+	"1824": "Transport Discovery",
+	"1825": "Object Transfer",
+	"1826": "Fitness Machine",
+	"1827": "Mesh Provisioning",
+	"1828": "Mesh Proxy",
+	"1829": "Reconnection Configuration",
+	"183a": "Insulin Delivery",
+	"183b": "Binary Sensor",
+	"183c": "Emergency Configuration",
+	"183d": "Authorization Control",
+	"183e": "Physical Activity Monitor",
+	"183f": "Elapsed Time",
+	"1840": "Generic Health Sensor",
+	"1843": "Audio Input Control",
+	"1844": "Volume Control",
+	"1845": "Volume Offset Control",
+	"1846": "Coordinated Set Identification",
+	"1847": "Device Time",
+	"1848": "Media Control",
+	"1849": "Generic Media Control",
+	"184a": "Constant Tone Extension",
+	"184b": "Telephone Bearer",
+	"184c": "Generic Telephone Bearer",
+	"184d": "Microphone Control",
+	"184e": "Audio Stream Control",
+	"184f": "Broadcast Audio Scan",
+	"1850": "Published Audio Capabilities",
+	"1851": "Basic Audio Announcement",
+	"1852": "Broadcast Audio Announcement",
+	"1853": "Common Audio",
+	"1854": "Hearing Access",
+	"1855": "Telephony and Media Audio",
+	"1856": "Public Broadcast Announcement",
+	"1857": "Electronic Shelf Label",
+	"1858": "Gaming Audio",
+	"1859": "Mesh Proxy Solicitation",
+	"fcd2": "BTHome",
+	"fd3d": "SwitchBot",
+	"fd5a": "Samsung SmartTag",
+	"fd6f": "Exposure Notification",
+	"fdcd": "Qingping",
+	"fe0f": "Philips Hue",
+	"fe2c": "Google Fast Pair",
+	"fe95": "Xiaomi",
+	"fe9a": "Estimote",
+	"fe9f": "Google",
+	"feaa": "Eddystone",
+	"feec": "Tile",
+	"feed": "Tile",
+}
+
+// Service is a service UUID a device advertises, with its name if it's known
+type Service struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name,omitempty"`
+}
+
+// the name of the service UUID, given as 16-bit or in full, "" if unknown
+func serviceName(uuid string) string {
+	uuid = strings.ToLower(uuid)
+	if u, ok := bluetoothUUID16(uuid); ok {
+		uuid = formatLEUUID([]byte{byte(u), byte(u >> 8)})
+	}
+	return serviceNames[uuid]
+}
+
+// the services the device advertises, in its service UUID lists and its
+// service data
+func advertisedServices(a ble.Advertisement, ad *AdvertisingData) []Service {
+	uuids := []string{}
+	if ad != nil {
+		uuids = append(uuids, ad.Services...)
+		for _, data := range ad.ServiceData {
+			uuids = append(uuids, data.UUID)
+		}
+	} else {
+		for _, u := range a.Services() {
+			uuids = append(uuids, formatLEUUID(u))
+		}
+	}
+	services := []Service{}
+	seen := map[string]bool{}
+	for _, uuid := range uuids {
+		if seen[uuid] {
+			continue
+		}
+		seen[uuid] = true
+		services = append(services, Service{UUID: uuid, Name: serviceName(uuid)})
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	return services
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Session is a logged in user of the web UI
+type Session struct {
+	User    string
+	Role    string
+	CSRF    string
+	Expires time.Time
+}
+
+const sessionCookie = "blueblue_session"
+const csrfCookie = "blueblue_csrf"
+const sessionLifetime = 24 * time.Hour
+
+var sessions = map[string]*Session{}
+var sessionsMutex sync.Mutex
+
+// users and their bcrypt password hashes from the htpasswd file
+var htpasswd = map[string][]byte{}
+
+// users and their bcrypt password hashes from the configuration file, kept
+// apart from the configuration so they aren't shown by the API or saved in
+// the state file
+var configUsers = map[string][]byte{}
+var configUsersMutex sync.Mutex
+
+// load an htpasswd file with bcrypt hashes, as made by htpasswd -B
+func loadHtpasswd(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		htpasswd[parts[0]] = []byte(parts[1])
+	}
+	return scanner.Err()
+}
+
+// read the users in the configuration file, in users as a map of names to
+// bcrypt hashes like in an htpasswd file, replacing the users read before
+func readUsers(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := struct {
+		Users map[string]string `json:"users"`
+	}{}
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return err
+	}
+	users := map[string][]byte{}
+	for user, hash := range file.Users {
+		users[user] = []byte(hash)
+	}
+	configUsersMutex.Lock()
+	configUsers = users
+	configUsersMutex.Unlock()
+	return nil
+}
+
+// check if there are users in the configuration file
+func hasConfigUsers() bool {
+	configUsersMutex.Lock()
+	defer configUsersMutex.Unlock()
+	return len(configUsers) > 0
+}
+
+// check the user's password against the basic auth credentials, the
+// htpasswd file and the users in the configuration file
+func validCredentials(user, password string) bool {
+	given := []byte(user + ":" + password)
+	if *basicAuth != "" {
+		for _, credentials := range strings.Split(*basicAuth, ",") {
+			if subtle.ConstantTimeCompare([]byte(credentials), given) == 1 {
+				return true
+			}
+		}
+	}
+	hash, ok := htpasswd[user]
+	if !ok {
+		configUsersMutex.Lock()
+		hash, ok = configUsers[user]
+		configUsersMutex.Unlock()
+	}
+	return ok && bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// a random token for sessions and CSRF protection
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// the session of the request, or nil if it has none
+func requestSession(r *http.Request) *Session {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	session, ok := sessions[cookie.Value]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(session.Expires) {
+		delete(sessions, cookie.Value)
+		return nil
+	}
+	return session
+}
+
+// forget the sessions that have expired, which are otherwise only forgotten
+// when they're used again
+func forgetSessions() {
+	for range time.Tick(time.Minute) {
+		sessionsMutex.Lock()
+		for id, session := range sessions {
+			if time.Now().After(session.Expires) {
+				delete(sessions, id)
+			}
+		}
+		sessionsMutex.Unlock()
+	}
+}
+
+// check if the request changes anything. Scanning is started and stopped
+// with GETs, so those count too
+func changes(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return r.URL.Path == "/start" || r.URL.Path == "/stop"
+	}
+	return true
+}
+
+// check the request carries the session's CSRF token, if it changes anything
+func validCSRF(r *http.Request, session *Session) bool {
+	if !changes(r) {
+		return true
+	}
+	token := r.Header.Get("X-CSRF-Token")
+	if token == "" {
+		token = r.FormValue("csrf")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRF)) == 1
+}
+
+// check the request wasn't made by another site, if it changes anything.
+// Browsers send the basic auth credentials they have cached with requests
+// made by any site, like a form posted to blueblue, but say where requests
+// come from in the Sec-Fetch-Site header, or in Origin if they're older.
+// Scripts send neither
+func sameOrigin(r *http.Request) bool {
+	if !changes(r) {
+		return true
+	}
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// handler to show the login page (GET) or log in (POST)
+func login(w http.ResponseWriter, r *http.Request) {
+	failed := false
+	if r.Method == http.MethodPost {
+		if validCredentials(r.FormValue("user"), r.FormValue("password")) {
+			startSession(w, r, r.FormValue("user"))
+			http.Redirect(w, r, *basePath+"/", http.StatusSeeOther)
+			return
+		}
+		logger.Println("Failed login for", r.FormValue("user"), "from", r.RemoteAddr)
+		failed = true
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	t, _ := parseTemplate("login.html")
+	t.Execute(w, failed)
+}
+
+// start a session for the user, setting the session and CSRF cookies. The
+// CSRF cookie is readable by the pages, which send it back in a header
+func startSession(w http.ResponseWriter, r *http.Request, user string) {
+	id := randomToken()
+	session := &Session{User: user, Role: userRole(user), CSRF: randomToken(), Expires: time.Now().Add(sessionLifetime)}
+	sessionsMutex.Lock()
+	sessions[id] = session
+	sessionsMutex.Unlock()
+	secure := r.TLS != nil
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: *basePath + "/", Expires: session.Expires,
+		HttpOnly: true, Secure: secure, SameSite: http.SameSiteLaxMode})
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: session.CSRF, Path: *basePath + "/", Expires: session.Expires,
+		Secure: secure, SameSite: http.SameSiteStrictMode})
+	logger.Println("Logged in", user, "as", session.Role, "from", r.RemoteAddr)
+}
+
+// handler to log out (POST), ending the session
+func logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		sessionsMutex.Lock()
+		delete(sessions, cookie.Value)
+		sessionsMutex.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: *basePath + "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: "", Path: *basePath + "/", MaxAge: -1})
+	http.Redirect(w, r, *basePath+"/login", http.StatusSeeOther)
+}
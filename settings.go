@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sausheong/ble/linux/hci/cmd"
+)
+
+// ScanSettings are the HCI scan parameters, applied at the start of every
+// scan
+type ScanSettings struct {
+	// solicit scan responses from advertisers
+	Active bool `json:"active"`
+	// how often the controller scans, in milliseconds
+	Interval float64 `json:"interval"`
+	// how long each scan lasts, in milliseconds
+	Window float64 `json:"window"`
+	// only report devices with these addresses, filtered by the controller
+	Whitelist []string `json:"whitelist"`
+	// report every advertisement, instead of one per device per scan
+	Duplicates bool `json:"duplicates"`
+	// how long each scan lasts, in seconds
+	Duration float64 `json:"duration"`
+	// how long to turn the radio off between scans, in seconds
+	Sleep float64 `json:"sleep"`
+}
+
+// check the scan interval and window are within what the controller allows
+func (s ScanSettings) validate() error {
+	if s.Interval < 2.5 || s.Interval > 10240 {
+		return fmt.Errorf("invalid interval %gms, must be between 2.5ms and 10240ms", s.Interval)
+	}
+	if s.Window < 2.5 || s.Window > s.Interval {
+		return fmt.Errorf("invalid window %gms, must be between 2.5ms and the interval", s.Window)
+	}
+	if s.Duration <= 0 {
+		return fmt.Errorf("invalid duration %gs, must be more than 0s", s.Duration)
+	}
+	if s.Sleep < 0 {
+		return fmt.Errorf("invalid sleep %gs, can't be negative", s.Sleep)
+	}
+	for _, address := range s.Whitelist {
+		_, err := whitelistAddress(address)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// how long each scan lasts
+func (s ScanSettings) scanDuration() time.Duration {
+	return time.Duration(s.Duration * float64(time.Second))
+}
+
+// how long the radio is off between scans
+func (s ScanSettings) sleepDuration() time.Duration {
+	return time.Duration(s.Sleep * float64(time.Second))
+}
+
+var scanSettings ScanSettings
+var scanSettingsMutex sync.Mutex
+
+// the current scan settings
+func currentScanSettings() ScanSettings {
+	scanSettingsMutex.Lock()
+	defer scanSettingsMutex.Unlock()
+	return scanSettings
+}
+
+// change the scan settings, they take effect from the next scan
+func setScanSettings(s ScanSettings) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	scanSettingsMutex.Lock()
+	scanSettings = s
+	scanSettingsMutex.Unlock()
+	logger.Println("Changed scan settings to", s)
+	return nil
+}
+
+// the LE Set Scan Parameters command for the scan settings
+func scanParameters(s ScanSettings) *cmd.LESetScanParameters {
+	params := &cmd.LESetScanParameters{
+		LEScanType:     0x00, // passive
+		LEScanInterval: uint16(s.Interval / 0.625),
+		LEScanWindow:   uint16(s.Window / 0.625),
+	}
+	if s.Active {
+		params.LEScanType = 0x01
+	}
+	if len(s.Whitelist) > 0 {
+		params.ScanningFilterPolicy = 0x01
+	}
+	return params
+}
+
+// convert an address to the controller's byte order, which is reversed
+func whitelistAddress(address string) ([6]byte, error) {
+	var b [6]byte
+	mac, err := net.ParseMAC(address)
+	if err != nil || len(mac) != 6 {
+		return b, fmt.Errorf("invalid whitelist address %q", address)
+	}
+	for i := range b {
+		b[i] = mac[5-i]
+	}
+	return b, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// closed when blueblue is shutting down, so writers flush what they have
+var shuttingDown = make(chan struct{})
+
+// the writers that have to flush before blueblue exits
+var flushing sync.WaitGroup
+
+// the MQTT client, if connected, so it can be disconnected on shutdown
+var mqttClient mqtt.Client
+
+// the context of the web server's requests, cancelled when blueblue is
+// shutting down so the streams of events and notifications end instead of
+// holding up the web server's shutdown
+func serverContext(net.Listener) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-shuttingDown
+		cancel()
+	}()
+	return ctx
+}
+
+// shut down cleanly on SIGINT or SIGTERM, stopping the scan, flushing pending
+// writes, closing the adapters and finally the web server, if there is one,
+// which gets its own time to finish the requests it's serving
+func shutdownOnSignal(server *http.Server) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-ch
+	logger.Println("Shutting down on", sig)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stop.Store(true)
+	waitFor(ctx, scanning.Wait)
+	endAdvertising()
+	close(shuttingDown)
+	waitFor(ctx, flushing.Wait)
+	if grpcServer != nil {
+		waitFor(ctx, grpcServer.GracefulStop)
+	}
+	if mqttClient != nil {
+		mqttClient.Disconnect(250)
+	}
+	if db != nil {
+		db.Close()
+	}
+	adaptersMutex.Lock()
+	closeAdapters(adapters)
+	adaptersMutex.Unlock()
+
+	if server == nil {
+		return
+	}
+	serverCtx, serverCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer serverCancel()
+	err := server.Shutdown(serverCtx)
+	if err != nil {
+		logger.Println("Cannot shut down web server:", err)
+	}
+}
+
+// wait for f to return, or the deadline, whichever comes first
+func waitFor(ctx context.Context, f func()) {
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Println("Gave up waiting during shutdown:", ctx.Err())
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// advertising report event types
+const (
+	advInd        = 0x00
+	advScanInd    = 0x02
+	advNonconnInd = 0x03
+	scanRsp       = 0x04
+)
+
+// a simulated device, which advertises with a payload made afresh each time
+type simulatedDevice struct {
+	address     [6]byte
+	addressType byte
+	eventType   byte
+	interval    time.Duration
+	rssi        int
+	next        time.Time
+	// the advertising data and scan response, if any
+	payload func() (adv, rsp []byte)
+}
+
+// an LE advertising report event with a single report, as the HCI sends it.
+// The address is given the way it's written, most significant byte first
+func advertisingReport(eventType, addressType byte, address [6]byte, data []byte, rssi int) []byte {
+	report := []byte{0x02, 1, eventType, addressType}
+	for i := 5; i >= 0; i-- {
+		report = append(report, address[i])
+	}
+	report = append(report, byte(len(data)))
+	report = append(report, data...)
+	return append(report, byte(int8(rssi)))
+}
+
+// an AD structure
+func adStructure(typ byte, value ...byte) []byte {
+	return append([]byte{byte(len(value) + 1), typ}, value...)
+}
+
+// a random address of the given type, a static random address if random
+func randomAddress(addressType byte) (address [6]byte) {
+	rand.Read(address[:])
+	if addressType == addressRandom {
+		address[0] |= 0xc0
+	}
+	return
+}
+
+// a public address with an OUI of the given vendor
+func vendorAddress(oui ...byte) (address [6]byte) {
+	copy(address[:], oui)
+	rand.Read(address[3:])
+	return
+}
+
+// a value that wanders randomly by up to step, between min and max
+func wander(value, step, min, max float64) float64 {
+	value += (rand.Float64()*2 - 1) * step
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// make a few simulated beacons, phones and sensors
+func simulatedDevices() []*simulatedDevice {
+	flags := adStructure(0x01, 0x06)
+	list := []*simulatedDevice{}
+	// iBeacons
+	for minor := uint16(1); minor <= 3; minor++ {
+		ibeacon := []byte{0x4c, 0x00, 0x02, 0x15,
+			0xf7, 0x82, 0x6d, 0xa6, 0x4f, 0xa2, 0x4e, 0x98, 0x80, 0x24, 0xbc, 0x5b, 0x71, 0xe0, 0x89, 0x3e,
+			0x00, 0x01, byte(minor >> 8), byte(minor), 0xc5}
+		data := append(append([]byte{}, flags...), adStructure(0xff, ibeacon...)...)
+		list = append(list, &simulatedDevice{
+			address: randomAddress(addressPublic), addressType: addressPublic, eventType: advNonconnInd,
+			interval: 350 * time.Millisecond,
+			payload:  func() ([]byte, []byte) { return data, nil },
+		})
+	}
+	// an Eddystone-URL beacon for https://github.com
+	eddystone := adStructure(0x16, append([]byte{0xaa, 0xfe, 0x10, 0xeb, 0x03}, "github.com"...)...)
+	list = append(list, &simulatedDevice{
+		address: randomAddress(addressRandom), addressType: addressRandom, eventType: advNonconnInd,
+		interval: time.Second,
+		payload: func() ([]byte, []byte) {
+			return append(append(append([]byte{}, flags...), adStructure(0x03, 0xaa, 0xfe)...), eddystone...), nil
+		},
+	})
+	// phones, with Apple Nearby Info and a connectable Android phone
+	list = append(list, &simulatedDevice{
+		address: randomAddress(addressRandom), addressType: addressRandom, eventType: advInd,
+		interval: 200 * time.Millisecond,
+		payload: func() ([]byte, []byte) {
+			return append(append([]byte{}, flags...), adStructure(0xff, 0x4c, 0x00, 0x10, 0x05, 0x01, 0x18, 0x5a, 0x3c, 0x1e)...), nil
+		},
+	})
+	list = append(list, &simulatedDevice{
+		address: randomAddress(addressRandom), addressType: addressRandom, eventType: advInd,
+		interval: 500 * time.Millisecond,
+		payload: func() ([]byte, []byte) {
+			return append(append([]byte{}, flags...), adStructure(0x03, 0x2c, 0xfe)...),
+				adStructure(0x09, []byte("Pixel 7")...)
+		},
+	})
+	// a temperature sensor, with its readings in Environmental Sensing service
+	// data, and a heart rate monitor with a battery
+	temperature := 22.0
+	list = append(list, &simulatedDevice{
+		address: vendorAddress(0xa4, 0xc1, 0x38), addressType: addressPublic, eventType: advInd,
+		interval: 2 * time.Second,
+		payload: func() ([]byte, []byte) {
+			temperature = wander(temperature, 0.1, 15, 30)
+			value := make([]byte, 2)
+			binary.LittleEndian.PutUint16(value, uint16(int16(temperature*100)))
+			data := append(append([]byte{}, flags...), adStructure(0x16, append([]byte{0x1a, 0x18}, value...)...)...)
+			return data, adStructure(0x09, []byte("Thermometer")...)
+		},
+	})
+	list = append(list, &simulatedDevice{
+		address: vendorAddress(0x00, 0x22, 0xd0), addressType: addressPublic, eventType: advInd,
+		interval: time.Second,
+		payload: func() ([]byte, []byte) {
+			data := append(append([]byte{}, flags...), adStructure(0x03, 0x0d, 0x18, 0x0f, 0x18)...)
+			return data, adStructure(0x09, []byte("HRM Pro")...)
+		},
+	})
+	for _, d := range list {
+		d.rssi = -40 - rand.Intn(50)
+		d.next = time.Now().Add(time.Duration(rand.Int63n(int64(d.interval))))
+	}
+	return list
+}
+
+// simulatorScanner makes up the advertisements of simulated devices
+type simulatorScanner struct {
+	devices   []*simulatedDevice
+	whitelist map[string]bool
+}
+
+// open the simulator, with a new set of devices
+func openSimulator() (Scanner, error) {
+	return &simulatorScanner{devices: simulatedDevices()}, nil
+}
+
+// only report the devices in the whitelist, if there is one, like the
+// controller does
+func (s *simulatorScanner) Configure(settings ScanSettings) error {
+	s.whitelist = map[string]bool{}
+	for _, address := range settings.Whitelist {
+		s.whitelist[strings.ToLower(address)] = true
+	}
+	return nil
+}
+
+// advertise the simulated devices until the context is done, with their RSSI
+// wandering as if they were moving about. Without duplicates, each device is
+// reported once per scan, like the controller does
+func (s *simulatorScanner) Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	reported := map[*simulatedDevice]bool{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			for _, d := range s.devices {
+				if now.Before(d.next) {
+					continue
+				}
+				d.next = now.Add(d.interval)
+				d.rssi = int(wander(float64(d.rssi), 3, -100, -35))
+				address := net.HardwareAddr(d.address[:]).String()
+				if (len(s.whitelist) > 0 && !s.whitelist[address]) || (!duplicates && reported[d]) {
+					continue
+				}
+				reported[d] = true
+				data, rsp := d.payload()
+				a := &replayedAdvertisement{e: advertisingReport(d.eventType, d.addressType, d.address, data, d.rssi)}
+				if rsp != nil {
+					a.sr = advertisingReport(scanRsp, d.addressType, d.address, rsp, d.rssi)
+				}
+				handler(a)
+			}
+		}
+	}
+}
+
+func (s *simulatorScanner) Unplugged() bool {
+	return false
+}
+
+func (s *simulatorScanner) Address() string {
+	return ""
+}
+
+func (s *simulatorScanner) Close() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// RSSI filters
+const (
+	FilterNone   = "none"
+	FilterEWMA   = "ewma"
+	FilterKalman = "kalman"
+)
+
+// Smoothing is how the RSSI of each device is smoothed, since it jumps
+// around from one advertisement to the next
+type Smoothing struct {
+	// none, ewma or kalman
+	Filter string `json:"filter"`
+	// weight of the latest RSSI in the exponentially weighted moving average
+	Alpha float64 `json:"alpha"`
+	// how much the RSSI is expected to change between advertisements, and how
+	// noisy it is, as variances in dBm², for the Kalman filter
+	ProcessNoise     float64 `json:"processnoise"`
+	MeasurementNoise float64 `json:"measurementnoise"`
+}
+
+// check the smoothing settings are usable
+func (s Smoothing) validate() error {
+	switch s.Filter {
+	case "", FilterNone:
+	case FilterEWMA:
+		if s.Alpha <= 0 || s.Alpha > 1 {
+			return fmt.Errorf("invalid alpha %g, must be more than 0 and at most 1", s.Alpha)
+		}
+	case FilterKalman:
+		if s.ProcessNoise <= 0 || s.MeasurementNoise <= 0 {
+			return fmt.Errorf("invalid Kalman filter noise %g and %g, must be more than 0", s.ProcessNoise, s.MeasurementNoise)
+		}
+	default:
+		return fmt.Errorf("invalid RSSI filter %q, must be none, ewma or kalman", s.Filter)
+	}
+	return nil
+}
+
+// smooth the device's latest RSSI with its previous smoothed RSSI, if it
+// has one
+func (s Smoothing) smooth(device *Device, previous Device, found bool) {
+	rssi := float64(device.RSSI)
+	if !found || previous.SmoothedRSSI == 0 {
+		device.SmoothedRSSI = rssi
+		device.rssiVariance = s.MeasurementNoise
+		return
+	}
+	switch s.Filter {
+	case FilterEWMA:
+		device.SmoothedRSSI = s.Alpha*rssi + (1-s.Alpha)*previous.SmoothedRSSI
+	case FilterKalman:
+		variance := previous.rssiVariance + s.ProcessNoise
+		gain := variance / (variance + s.MeasurementNoise)
+		device.SmoothedRSSI = previous.SmoothedRSSI + gain*(rssi-previous.SmoothedRSSI)
+		device.rssiVariance = (1 - gain) * variance
+	default:
+		device.SmoothedRSSI = rssi
+	}
+	device.SmoothedRSSI = math.Round(device.SmoothedRSSI*10) / 10
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"sync"
+)
+
+// State is what blueblue was doing, saved so it can resume after a crash or
+// reboot
+type State struct {
+	Scanning  bool     `json:"scanning"`
+	Config    Config   `json:"config"`
+	Adapters  []string `json:"adapters"`
+	Schedules []string `json:"schedules"`
+}
+
+// whether scanning should be on, as opposed to whether it is
+var scanWanted bool
+var stateMutex sync.Mutex
+
+// start scanning and remember it, returns false if scanning is already on
+func startScanning() bool {
+	stateMutex.Lock()
+	if !stop.Load() {
+		stateMutex.Unlock()
+		return false
+	}
+	scanWanted = true
+	runScan()
+	stateMutex.Unlock()
+	saveState()
+	return true
+}
+
+// stop scanning and remember it, returns false if scanning is already off
+func stopScanning() bool {
+	stateMutex.Lock()
+	if stop.Load() {
+		stateMutex.Unlock()
+		return false
+	}
+	scanWanted = false
+	stop.Store(true)
+	stateMutex.Unlock()
+	saveState()
+	return true
+}
+
+// scan in the background, once the scanning stopped before has finished so
+// the adapters aren't scanned on twice. Must be called with the state mutex
+// held
+func runScan() {
+	scanning.Wait()
+	stop.Store(false)
+	scanning.Add(1)
+	go scan()
+}
+
+// save the current state to the state file, if there is one. Only the
+// server saves it, so a one-off scan doesn't overwrite the state of a
+// server using the same file
+func saveState() {
+	if *statePath == "" || command != "serve" {
+		return
+	}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	state := State{Scanning: scanWanted, Config: currentConfig()}
+	adaptersMutex.Lock()
+	for _, adapter := range adapters {
+		state.Adapters = append(state.Adapters, adapter.Name)
+	}
+	adaptersMutex.Unlock()
+	for _, s := range scheduleList() {
+		state.Schedules = append(state.Schedules, s.Cron)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		logger.Println("Cannot encode state:", err)
+		return
+	}
+	// write to a temporary file first so a crash doesn't leave half a state
+	err = os.WriteFile(*statePath+".tmp", data, 0644)
+	if err == nil {
+		err = os.Rename(*statePath+".tmp", *statePath)
+	}
+	if err != nil {
+		logger.Println("Cannot save state:", err)
+	}
+}
+
+// load the state saved in the state file, returns nil if there is none
+func loadState() (*State, error) {
+	if *statePath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(*statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// whether the flag was given on the command line or as an environment
+// variable
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		given = given || f.Name == name
+	})
+	return given
+}
+
+// the configuration saved in the state, with the settings whose flags were
+// given taken from the configuration made from the flags instead
+func resumedConfig(saved, flags Config) Config {
+	c := saved
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "active":
+			c.Scan.Active = flags.Scan.Active
+		case "scan-interval":
+			c.Scan.Interval = flags.Scan.Interval
+		case "scan-window":
+			c.Scan.Window = flags.Scan.Window
+		case "duplicates":
+			c.Scan.Duplicates = flags.Scan.Duplicates
+		case "whitelist":
+			c.Scan.Whitelist = flags.Scan.Whitelist
+		case "d":
+			c.Scan.Duration = flags.Scan.Duration
+		case "sleep":
+			c.Scan.Sleep = flags.Scan.Sleep
+		case "rssi-filter":
+			c.Smoothing.Filter = flags.Smoothing.Filter
+		case "rssi-alpha":
+			c.Smoothing.Alpha = flags.Smoothing.Alpha
+		case "path-loss":
+			c.PathLoss = flags.PathLoss
+		case "expiry":
+			c.Expiry = flags.Expiry
+		case "mqtt-topic":
+			c.MQTTTopic = flags.MQTTTopic
+		case "mqtt-qos":
+			c.MQTTQoS = flags.MQTTQoS
+		case "battery-low":
+			c.BatteryLow = flags.BatteryLow
+		case "tracker-time":
+			c.TrackerAlert = flags.TrackerAlert
+		}
+	})
+	return c
+}
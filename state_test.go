@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// countingScanner counts the scans running on it at once
+type countingScanner struct {
+	mutex        sync.Mutex
+	running, max int
+}
+
+func (c *countingScanner) Configure(s ScanSettings) error { return nil }
+
+func (c *countingScanner) Scan(ctx context.Context, duplicates bool, handler ble.AdvHandler) error {
+	c.mutex.Lock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.mutex.Unlock()
+	time.Sleep(time.Millisecond)
+	c.mutex.Lock()
+	c.running--
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *countingScanner) Unplugged() bool { return false }
+func (c *countingScanner) Address() string { return "" }
+func (c *countingScanner) Close() error    { return nil }
+
+func TestRestartScanning(t *testing.T) {
+	scanner := &countingScanner{}
+	adapters = []*Adapter{{Name: "counting", Scanner: scanner, state: AdapterOK}}
+	for i := 0; i < 20; i++ {
+		if !startScanning() || !stopScanning() {
+			t.Fatal("scanning wasn't started and stopped")
+		}
+	}
+	if !startScanning() {
+		t.Fatal("scanning wasn't started")
+	}
+	if startScanning() {
+		t.Error("scanning started twice")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if stop.Load() {
+		t.Error("scanning stopped by a scan that was stopped before")
+	}
+	stopScanning()
+	scanning.Wait()
+	if scanner.max != 1 {
+		t.Errorf("%d scans ran at once, want 1", scanner.max)
+	}
+}
+
+func TestResumedConfigKeepsFlagsGiven(t *testing.T) {
+	topic := *mqttTopic
+	defer func() { *mqttTopic = topic }()
+	err := flag.Set("mqtt-topic", "home/ble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := Config{MQTTTopic: "saved/ble", Expiry: 120, BatteryLow: 10}
+	flags := Config{MQTTTopic: *mqttTopic, Expiry: 60, BatteryLow: 20}
+	c := resumedConfig(saved, flags)
+	if c.MQTTTopic != "home/ble" || c.Expiry != 120 || c.BatteryLow != 10 {
+		t.Errorf("got %+v, want the saved expiry and battery low level with the topic given", c)
+	}
+	if !flagGiven("mqtt-topic") || flagGiven("expiry") {
+		t.Error("flags given aren't told from the defaults")
+	}
+}
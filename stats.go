@@ -0,0 +1,38 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"time"
+)
+
+// internal counters published at /debug/vars, for a quick look without
+// Prometheus
+var (
+	statAdvertisements = expvar.NewInt("advertisements")
+	statScanCycles     = expvar.NewInt("scan_cycles")
+	// number of requests and total time taken in milliseconds, by handler
+	statRequests       = expvar.NewMap("handler_requests")
+	statRequestsTimeMs = expvar.NewMap("handler_time_ms")
+)
+
+func init() {
+	expvar.Publish("devices_tracked", expvar.Func(func() interface{} {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		return len(devices)
+	}))
+	expvar.Publish("devices_visible", expvar.Func(func() interface{} {
+		return len(deviceList())
+	}))
+}
+
+// wrap the handler to count requests and the time taken by them
+func timeHandler(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		handler.ServeHTTP(w, r)
+		statRequests.Add(name, 1)
+		statRequestsTimeMs.AddFloat(name, float64(time.Since(start))/float64(time.Millisecond))
+	})
+}
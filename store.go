@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var db *sql.DB
+
+// devices restored from the database that haven't been seen since, kept
+// apart from the visible devices so they don't expire straight away.
+// Protected by the device mutex
+var knownDevices = make(map[string]Device)
+
+// detections waiting to be written to the database
+var detections = make(chan Device, 1024)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS detections (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	address        TEXT NOT NULL,
+	name           TEXT,
+	rssi           INTEGER,
+	detected       TIMESTAMP NOT NULL,
+	advertisement  TEXT,
+	scan_response  TEXT
+);
+CREATE INDEX IF NOT EXISTS detections_address ON detections (address, detected);
+`
+
+// open the SQLite database, create the schema and restore known devices
+func openStore(path string) (err error) {
+	db, err = sql.Open("sqlite3", path)
+	if err != nil {
+		return
+	}
+	_, err = db.Exec(schema)
+	if err != nil {
+		return
+	}
+	err = restoreDevices()
+	if err != nil {
+		return
+	}
+	flushing.Add(1)
+	go writeDetections()
+	return
+}
+
+// load the last detection of every known device into the known devices, with
+// when it was first detected
+func restoreDevices() error {
+	rows, err := db.Query(`SELECT address, name, rssi, MAX(detected), advertisement, scan_response,
+		(SELECT MIN(detected) FROM detections AS first WHERE first.address = detections.address)
+		FROM detections GROUP BY address`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	mutex.Lock()
+	defer mutex.Unlock()
+	count := 0
+	for rows.Next() {
+		device := Device{}
+		var detected, firstSeen string
+		err = rows.Scan(&device.Address, &device.Name, &device.RSSI, &detected,
+			&device.Advertisement, &device.ScanResponse, &firstSeen)
+		if err != nil {
+			return err
+		}
+		device.Detected, err = parseTimestamp(detected)
+		if err != nil {
+			return err
+		}
+		device.FirstSeen, err = parseTimestamp(firstSeen)
+		if err != nil {
+			return err
+		}
+		knownDevices[device.Address] = device
+		count++
+	}
+	logger.Println("Restored", count, "devices from the database")
+	return rows.Err()
+}
+
+// parse a timestamp in one of the formats the sqlite3 driver writes
+func parseTimestamp(s string) (t time.Time, err error) {
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999-07:00",
+		"2006-01-02T15:04:05.999999999-07:00",
+		time.RFC3339Nano,
+	} {
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// queue a detection to be written to the database, if there is one
+func recordDetection(device Device) {
+	if db == nil {
+		return
+	}
+	select {
+	case detections <- device:
+	default:
+		// drop the detection rather than block the scan handler
+	}
+}
+
+// write queued detections to the database in batches, once a second, until
+// shutdown
+func writeDetections() {
+	defer flushing.Done()
+	batch := []Device{}
+	ticker := time.NewTicker(time.Second)
+	for {
+		select {
+		case device := <-detections:
+			batch = append(batch, device)
+		case <-shuttingDown:
+			for len(detections) > 0 {
+				batch = append(batch, <-detections)
+			}
+			if len(batch) > 0 {
+				err := insertDetections(batch)
+				if err != nil {
+					logger.Println("Cannot write detections:", err)
+				}
+			}
+			return
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+			err := insertDetections(batch)
+			if err != nil {
+				logger.Println("Cannot write detections:", err)
+			}
+			batch = batch[:0]
+		}
+	}
+}
+
+// insert the detections in a single transaction
+func insertDetections(batch []Device) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO detections
+		(address, name, rssi, detected, advertisement, scan_response)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, device := range batch {
+		_, err = stmt.Exec(device.Address, device.Name, device.RSSI, device.Detected,
+			device.Advertisement, device.ScanResponse)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,69 @@
+package main
+
+import "github.com/sausheong/ble"
+
+// SwitchBot service UUIDs, the one older devices use and the one assigned to
+// Woan Technology
+var switchBotUUIDs = []ble.UUID{ble.UUID16(0x0d00), ble.UUID16(0xfd3d)}
+
+func init() {
+	for _, uuid := range switchBotUUIDs {
+		registerServiceDataDecoder(uuid, "switchbot", decodeSwitchBot)
+	}
+}
+
+// decode SwitchBot service data, where the first byte is the kind of device
+// as an ASCII letter, followed by its state and battery level
+func decodeSwitchBot(data []byte, sensor *Sensor) bool {
+	if len(data) < 3 {
+		return false
+	}
+	flags := data[1]
+	battery := int(data[2] & 0x7f)
+	switch data[0] & 0x7f {
+	// Bot, in press or switch mode, where it's on or off
+	case 'H':
+		on := 0.0
+		if flags&0x80 != 0 && flags&0x40 == 0 {
+			on = 1
+		}
+		sensor.setValues(map[string]float64{"switchmode": bit(flags&0x80 != 0), "on": on})
+	// Meter and Meter Plus, with the temperature as a sign bit and integer
+	// and tenths of a degree
+	case 'T', 'i':
+		if len(data) < 6 {
+			return false
+		}
+		temperature := float64(data[4]&0x7f) + float64(data[3]&0x0f)/10
+		if data[4]&0x80 == 0 {
+			temperature = -temperature
+		}
+		humidity := float64(data[5] & 0x7f)
+		sensor.Temperature, sensor.Humidity = &temperature, &humidity
+	// Contact Sensor, whether it's open, the motion and light it senses, and
+	// a counter of its button presses
+	case 'd':
+		if len(data) < 9 {
+			return false
+		}
+		sensor.setValues(map[string]float64{
+			"open":        bit(data[3]&0x02 != 0),
+			"opentimeout": bit(data[3]&0x06 == 0x06),
+			"motion":      bit(flags&0x40 != 0),
+			"light":       bit(data[3]&0x01 != 0),
+			"buttoncount": float64(data[8] & 0x0f),
+		})
+	default:
+		return false
+	}
+	sensor.Battery = &battery
+	return true
+}
+
+// 1 if true, 0 if false, for readings that are on or off
+func bit(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestDecodeSwitchBot(t *testing.T) {
+	testDecoder(t, decodeSwitchBot, []decoderTest{
+		{"meter", "54 00 64 05 99 2d", map[string]float64{"temperature": 25.5, "humidity": 45, "battery": 100}},
+		{"meter below zero", "54 00 64 05 05 2d", map[string]float64{"temperature": -5.5, "humidity": 45, "battery": 100}},
+		{"meter plus", "69 00 e4 03 96 b2", map[string]float64{"temperature": 22.3, "humidity": 50, "battery": 100}},
+		{"encrypted meter", "d4 00 64 05 99 2d", map[string]float64{"temperature": 25.5, "humidity": 45, "battery": 100}},
+		{"bot switched on", "48 80 5a", map[string]float64{"switchmode": 1, "on": 1, "battery": 90}},
+		{"bot switched off", "48 c0 5a", map[string]float64{"switchmode": 1, "on": 0, "battery": 90}},
+		{"bot pressing", "48 00 5a", map[string]float64{"switchmode": 0, "on": 0, "battery": 90}},
+		{"contact sensor open", "64 40 5a 03 00 00 00 00 02", map[string]float64{
+			"open": 1, "opentimeout": 0, "motion": 1, "light": 1, "buttoncount": 2, "battery": 90,
+		}},
+		{"contact sensor open too long", "64 00 5a 06 00 00 00 00 00", map[string]float64{
+			"open": 1, "opentimeout": 1, "motion": 0, "light": 0, "buttoncount": 0, "battery": 90,
+		}},
+		{"truncated meter", "54 00 64 05", nil},
+		{"truncated contact sensor", "64 40 5a 03 00", nil},
+		{"truncated", "54 00", nil},
+		{"unknown device", "5a 00 64", nil},
+	})
+}
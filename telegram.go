@@ -0,0 +1,21 @@
+package main
+
+// send a message for each of the events wanted to the Telegram chat, through
+// the bot
+func startTelegram() error {
+	messages, err := parseMessages(*messagesPath)
+	if err != nil {
+		return err
+	}
+	url := "https://api.telegram.org/bot" + *telegramToken + "/sendMessage"
+	go notify("Telegram", eventTypes(*telegramEvents), func(e Event) error {
+		text, err := eventMessage(messages, e)
+		if err != nil {
+			logger.Println("Cannot make Telegram message:", err)
+			return nil
+		}
+		return postJSON(url, map[string]string{"chat_id": *telegramChat, "text": text})
+	})
+	logger.Println("Sending", *telegramEvents, "events to Telegram chat", *telegramChat)
+	return nil
+}
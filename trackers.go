@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sausheong/ble"
+)
+
+// EventTracker is published when an unknown tracker has been following the
+// scanner for longer than the configured time
+const EventTracker = "tracker"
+
+// kinds of item trackers
+const (
+	TrackerFindMy   = "findmy"
+	TrackerSmartTag = "smarttag"
+	TrackerTile     = "tile"
+)
+
+// the service UUIDs of Samsung SmartTags and Tiles
+var (
+	smartTagUUID = ble.UUID16(0xfd5a)
+	tileUUIDs    = []ble.UUID{ble.UUID16(0xfeed), ble.UUID16(0xfeec)}
+)
+
+// Tracker is an item tracker like an AirTag, and how long it has been
+// following the scanner
+type Tracker struct {
+	Kind string `json:"kind"`
+	// since when it's been seen without being out of sight for longer than
+	// the expiry
+	Since time.Time `json:"since"`
+	// whether it's one of the user's own, which never raise an alert
+	Known   bool `json:"known"`
+	Alerted bool `json:"alerted"`
+	last    time.Time
+}
+
+// the trackers that are following the scanner, by group of private addresses
+// or address, protected by the device mutex
+var followers = make(map[string]*Tracker)
+
+// the kind of tracker the advertisement is from, or "" if it isn't from one.
+// Find My devices, like AirTags, are only trackers once they're separated
+// from their owner, when they advertise the full offline finding key
+func trackerKind(a ble.Advertisement) string {
+	if data := a.ManufacturerData(); len(data) >= 4 && data[0] == 0x4c && data[1] == 0x00 && data[2] == 0x12 && data[3] == 0x19 {
+		return TrackerFindMy
+	}
+	for _, sd := range a.ServiceData() {
+		if sd.UUID.Equal(smartTagUUID) {
+			return TrackerSmartTag
+		}
+	}
+	for _, u := range a.Services() {
+		if ble.Contains(tileUUIDs, u) {
+			return TrackerTile
+		}
+	}
+	for _, sd := range a.ServiceData() {
+		if ble.Contains(tileUUIDs, sd.UUID) {
+			return TrackerTile
+		}
+	}
+	return ""
+}
+
+// keep track of how long the tracker the device is has been following the
+// scanner, returns true if an alert should be raised for it. Must be called
+// with the device mutex held, after the device is put in its group
+func followTracker(device *Device, kind string, c Config) bool {
+	if kind == "" {
+		return false
+	}
+	key := device.Address
+	if device.Group != "" {
+		key = "group " + device.Group
+	}
+	follower, ok := followers[key]
+	if !ok {
+		follower = &Tracker{Kind: kind, Since: device.Detected}
+		followers[key] = follower
+	}
+	follower.last = device.Detected
+	follower.Known = device.PrivateAddress != "" || knownTracker(c, device)
+	alert := false
+	after := time.Duration(c.TrackerAlert * float64(time.Second))
+	if !follower.Known && !follower.Alerted && after > 0 && device.Detected.Sub(follower.Since) >= after {
+		follower.Alerted = true
+		alert = true
+	}
+	tracker := *follower
+	device.Tracker = &tracker
+	return alert
+}
+
+// whether the device's address or group is one of the known trackers
+func knownTracker(c Config, device *Device) bool {
+	for _, known := range c.KnownTrackers {
+		if known == device.Address || known == device.Group && device.Group != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// forget the trackers that haven't been seen since the cutoff, which start
+// following afresh if they're seen again. Must be called with the device
+// mutex held
+func expireTrackers(cutoff time.Time) {
+	for key, follower := range followers {
+		if follower.last.Before(cutoff) {
+			delete(followers, key)
+		}
+	}
+}
+
+// publish the tracker event and run the alert command, if there is one, with
+// the device address, kind of tracker and how many minutes it has been
+// following as arguments
+func alertTracker(device Device) {
+	following := device.Detected.Sub(device.Tracker.Since).Round(time.Minute)
+	logger.Println("Unknown", device.Tracker.Kind, "tracker", device.Address, "following for", following)
+	broker.Publish(Event{Type: EventTracker, Device: device})
+	if *trackerAlert == "" || quiet() {
+		return
+	}
+	runAlert("tracker", *trackerAlert, device.Address, device.Tracker.Kind, fmt.Sprint(int(following.Minutes())))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/endobit/oui"
+)
+
+// address types in the HCI advertising report
+const (
+	addressPublic = 0x00
+	addressRandom = 0x01
+)
+
+// addressTyped is implemented by advertisements that report the type of
+// the advertiser's address, like the linux HCI ones
+type addressTyped interface {
+	AddressType() uint8
+}
+
+// look up the vendor of the advertiser's address in the IEEE OUI registry,
+// only public addresses have a vendor
+func lookupVendor(a interface{}, address string) string {
+	typed, ok := a.(addressTyped)
+	if !ok || typed.AddressType() != addressPublic {
+		return ""
+	}
+	return oui.Vendor(address)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// Version is the build of blueblue that is running
+type Version struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	BuildDate  string `json:"builddate"`
+	GoVersion  string `json:"goversion"`
+	BLEVersion string `json:"bleversion"`
+}
+
+// the version of the running build, filling in what wasn't set at build
+// time from the build info Go embeds
+func currentVersion() Version {
+	v := Version{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/sausheong/ble" {
+			v.BLEVersion = dep.Version
+			if dep.Replace != nil {
+				v.BLEVersion = dep.Replace.Path + " " + dep.Replace.Version
+			}
+		}
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && v.Commit == "":
+			v.Commit = setting.Value
+		case setting.Key == "vcs.time" && v.BuildDate == "":
+			v.BuildDate = setting.Value
+		}
+	}
+	return v
+}
+
+// handler to show the version of the running build
+func apiVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentVersion())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// how many times a notification is tried, waiting twice as long after each
+// failure starting from the first backoff
+const (
+	notifyAttempts = 5
+	notifyBackoff  = time.Second
+)
+
+// the most notifications waiting to be sent to each receiver, more are
+// dropped
+const notifyQueue = 100
+
+// the client notifications are sent with
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// the event types in a comma-separated list
+func eventTypes(list string) map[string]bool {
+	types := map[string]bool{}
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	return types
+}
+
+// send the events of the types wanted to the receiver, one at a time, until
+// shutdown. Events during quiet hours are dropped, as are events that arrive
+// while too many are waiting to be sent
+func notify(name string, types map[string]bool, send func(Event) error) {
+	ch := broker.Subscribe()
+	queue := make(chan Event, notifyQueue)
+	go func() {
+		for e := range queue {
+			retry(name, func() error { return send(e) })
+		}
+	}()
+	defer broker.Unsubscribe(ch)
+	for {
+		select {
+		case e := <-ch:
+			if !types[e.Type] || quiet() {
+				continue
+			}
+			select {
+			case queue <- e:
+			default:
+				logger.Println("Cannot keep up with", name, "notifications, dropping", e.Type, "event")
+			}
+		case <-shuttingDown:
+			close(queue)
+			return
+		}
+	}
+}
+
+// try to send a notification until it succeeds, backing off after each
+// failure, and give up after a few attempts
+func retry(name string, send func() error) {
+	backoff := notifyBackoff
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil {
+			return
+		}
+		if attempt == notifyAttempts {
+			logger.Println("Cannot send", name, "notification, giving up:", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post the body as JSON to the URL, a response other than 2xx is an error.
+// Errors don't give the URL, since it may have a token in it
+func postJSON(url string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "blueblue/"+version)
+	resp, err := notifyClient.Do(req)
+	if err, ok := err.(*neturl.Error); ok {
+		// without the URL, which may have a token in it
+		return err.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got %s", resp.Status)
+	}
+	return nil
+}
+
+// post the events of the types wanted to every webhook
+func startWebhooks() {
+	types := eventTypes(*webhookEvents)
+	for _, url := range strings.Split(*webhooks, ",") {
+		url := strings.TrimSpace(url)
+		go notify("webhook", types, func(e Event) error {
+			return postJSON(url, e)
+		})
+	}
+	logger.Println("Posting", *webhookEvents, "events to webhooks")
+}
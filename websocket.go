@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{}
+
+// handler to stream device events over websocket
+func streamWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Println("Cannot upgrade to websocket:", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := broker.Subscribe()
+	defer broker.Unsubscribe(ch)
+
+	// read from the connection to detect when the client goes away
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case e := <-ch:
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-r.Context().Done():
+			closeWebSocket(conn)
+			return
+		}
+	}
+}
+
+// tell the client the server is going away, when blueblue is shutting down
+func closeWebSocket(conn *websocket.Conn) {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
+
+// stream characteristic values over websocket until the channel is closed
+// or the client goes away
+func streamNotificationsWebSocket(w http.ResponseWriter, r *http.Request, ch chan Value) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Println("Cannot upgrade to websocket:", err)
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case value, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(value); err != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-r.Context().Done():
+			closeWebSocket(conn)
+			return
+		}
+	}
+}
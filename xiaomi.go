@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/binary"
+
+	"github.com/sausheong/ble"
+)
+
+// Xiaomi MiBeacon service UUID, and the Environmental Sensing service UUID
+// that the ATC and pvvx custom firmware of Xiaomi thermometers like the
+// LYWSD03MMC advertise with
+var (
+	miBeaconUUID             = ble.UUID16(0xfe95)
+	environmentalSensingUUID = ble.UUID16(0x181a)
+)
+
+func init() {
+	registerServiceDataDecoder(miBeaconUUID, "mibeacon", decodeMiBeacon)
+	registerServiceDataDecoder(environmentalSensingUUID, "atc", decodeATC)
+	registerServiceDataDecoder(environmentalSensingUUID, "pvvx", decodePVVX)
+}
+
+// decode MiBeacon service data, a frame control, product ID and frame
+// counter followed by optional parts that the frame control says are there,
+// the last being the object with the readings. The stock firmware of many
+// thermometers encrypts the object, which can't be decoded without the
+// device's bind key
+func decodeMiBeacon(data []byte, sensor *Sensor) bool {
+	if len(data) < 5 {
+		return false
+	}
+	control := binary.LittleEndian.Uint16(data)
+	if control&0x0008 != 0 || control&0x0040 == 0 {
+		return false
+	}
+	data = data[5:]
+	// MAC address
+	if control&0x0010 != 0 {
+		if len(data) < 6 {
+			return false
+		}
+		data = data[6:]
+	}
+	// capability, and the I/O capability if it says so
+	if control&0x0020 != 0 {
+		if len(data) < 1 {
+			return false
+		}
+		capability := data[0]
+		data = data[1:]
+		if capability&0x20 != 0 {
+			if len(data) < 2 {
+				return false
+			}
+			data = data[2:]
+		}
+	}
+	if len(data) < 3 || len(data) < 3+int(data[2]) {
+		return false
+	}
+	kind, value := binary.LittleEndian.Uint16(data), data[3:3+int(data[2])]
+	switch {
+	case kind == 0x1004 && len(value) == 2:
+		temperature := float64(int16(binary.LittleEndian.Uint16(value))) / 10
+		sensor.Temperature = &temperature
+	case kind == 0x1006 && len(value) == 2:
+		humidity := float64(binary.LittleEndian.Uint16(value)) / 10
+		sensor.Humidity = &humidity
+	case kind == 0x100a && len(value) == 1:
+		battery := int(value[0])
+		sensor.Battery = &battery
+	case kind == 0x100d && len(value) == 4:
+		temperature := float64(int16(binary.LittleEndian.Uint16(value))) / 10
+		humidity := float64(binary.LittleEndian.Uint16(value[2:])) / 10
+		sensor.Temperature, sensor.Humidity = &temperature, &humidity
+	case kind == 0x1007 && len(value) == 3:
+		sensor.setValues(map[string]float64{"illuminance": float64(uint32(value[0]) | uint32(value[1])<<8 | uint32(value[2])<<16)})
+	case kind == 0x1008 && len(value) == 1:
+		sensor.setValues(map[string]float64{"moisture": float64(value[0])})
+	case kind == 0x1009 && len(value) == 2:
+		sensor.setValues(map[string]float64{"conductivity": float64(binary.LittleEndian.Uint16(value))})
+	default:
+		return false
+	}
+	return true
+}
+
+// decode the ATC custom firmware format, the MAC address, temperature in
+// tenths of a degree, humidity and battery in percent, battery in millivolts
+// and a frame counter, big-endian
+func decodeATC(data []byte, sensor *Sensor) bool {
+	if len(data) != 13 {
+		return false
+	}
+	temperature := float64(int16(binary.BigEndian.Uint16(data[6:]))) / 10
+	humidity := float64(data[8])
+	battery := int(data[9])
+	voltage := float64(binary.BigEndian.Uint16(data[10:])) / 1000
+	sensor.Temperature, sensor.Humidity = &temperature, &humidity
+	sensor.Battery, sensor.Voltage = &battery, &voltage
+	return true
+}
+
+// decode the pvvx custom firmware format, the MAC address, temperature and
+// humidity in hundredths, battery in millivolts and percent, a frame counter
+// and flags, little-endian
+func decodePVVX(data []byte, sensor *Sensor) bool {
+	if len(data) != 15 {
+		return false
+	}
+	temperature := float64(int16(binary.LittleEndian.Uint16(data[6:]))) / 100
+	humidity := float64(binary.LittleEndian.Uint16(data[8:])) / 100
+	voltage := float64(binary.LittleEndian.Uint16(data[10:])) / 1000
+	battery := int(data[12])
+	sensor.Temperature, sensor.Humidity = &temperature, &humidity
+	sensor.Battery, sensor.Voltage = &battery, &voltage
+	return true
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestDecodeMiBeacon(t *testing.T) {
+	testDecoder(t, decodeMiBeacon, []decoderTest{
+		{"temperature and humidity", "5000 5b05 01 aabbccddeeff 0d10 04 d200 ea01", map[string]float64{"temperature": 21, "humidity": 49}},
+		{"without MAC address", "4000 5b05 01 0d10 04 d200 ea01", map[string]float64{"temperature": 21, "humidity": 49}},
+		{"negative temperature", "4000 5b05 01 0410 02 9cff", map[string]float64{"temperature": -10}},
+		{"humidity", "4000 5b05 01 0610 02 ea01", map[string]float64{"humidity": 49}},
+		{"battery", "5000 5b05 01 aabbccddeeff 0a10 01 5d", map[string]float64{"battery": 93}},
+		{"capability", "6000 5b05 01 08 0a10 01 5d", map[string]float64{"battery": 93}},
+		{"I/O capability", "6000 5b05 01 28 0000 0a10 01 5d", map[string]float64{"battery": 93}},
+		{"illuminance", "4000 9800 01 0710 03 e80300", map[string]float64{"illuminance": 1000}},
+		{"moisture", "4000 9800 01 0810 01 1e", map[string]float64{"moisture": 30}},
+		{"conductivity", "4000 9800 01 0910 02 c800", map[string]float64{"conductivity": 200}},
+		{"encrypted", "5800 5b05 01 aabbccddeeff 0d10 04 d200 ea01", nil},
+		{"no object", "1000 5b05 01 aabbccddeeff", nil},
+		{"unknown object", "4000 5b05 01 ff10 01 00", nil},
+		{"wrong length for object", "4000 5b05 01 0d10 02 d200", nil},
+		{"truncated header", "5000 5b", nil},
+		{"truncated MAC address", "5000 5b05 01 aabb", nil},
+		{"truncated object", "4000 5b05 01 0d10 04 d200", nil},
+	})
+}
+
+func TestDecodeATC(t *testing.T) {
+	testDecoder(t, decodeATC, []decoderTest{
+		{"readings", "a4c138112233 00e1 32 5a 0b8a 01", map[string]float64{"temperature": 22.5, "humidity": 50, "battery": 90, "voltage": 2.954}},
+		{"negative temperature", "a4c138112233 ff9c 32 5a 0b8a 01", map[string]float64{"temperature": -10, "humidity": 50, "battery": 90, "voltage": 2.954}},
+		{"truncated", "a4c138112233 00e1 32 5a 0b8a", nil},
+		{"pvvx", "332211 38c1a4 ca08 8813 8a0b 5a 01 00", nil},
+	})
+}
+
+func TestDecodePVVX(t *testing.T) {
+	testDecoder(t, decodePVVX, []decoderTest{
+		{"readings", "332211 38c1a4 ca08 8813 8a0b 5a 01 00", map[string]float64{"temperature": 22.5, "humidity": 50, "battery": 90, "voltage": 2.954}},
+		{"negative temperature", "332211 38c1a4 18fc 8813 8a0b 5a 01 00", map[string]float64{"temperature": -10, "humidity": 50, "battery": 90, "voltage": 2.954}},
+		{"truncated", "332211 38c1a4 ca08 8813 8a0b 5a 01", nil},
+		{"atc", "a4c138112233 00e1 32 5a 0b8a 01", nil},
+	})
+}